@@ -0,0 +1,35 @@
+package api
+
+import (
+	"errors"
+	"go-server/internal/store"
+	"go-server/internal/utils"
+	"log/slog"
+	"net/http"
+)
+
+// writeStoreError answers with the problem matching a store sentinel error.
+// Anything unexpected is logged under msg and hidden behind a 500.
+func writeStoreError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, msg string, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		utils.NotFound(w, r, "the requested resource could not be found")
+	case errors.Is(err, store.ErrEditConflict):
+		utils.WriteError(w, r, http.StatusPreconditionFailed, utils.CodeEditConflict, "the resource was modified by another request, fetch it again and retry")
+	case errors.Is(err, store.ErrConflict):
+		utils.WriteError(w, r, http.StatusConflict, utils.CodeConflict, "the resource conflicts with an existing one")
+	case errors.Is(err, store.ErrUnknownExercise):
+		utils.FailedValidation(w, r, map[string]string{"exercise_id": "does not exist"})
+	case errors.Is(err, store.ErrUnknownEntry):
+		utils.FailedValidation(w, r, map[string]string{"entries": "contains an entry id that does not belong to this workout"})
+	case errors.Is(err, store.ErrCheckViolation):
+		utils.WriteError(w, r, http.StatusUnprocessableEntity, utils.CodeValidationFailed, "a value is outside the allowed range")
+	default:
+		serverError(w, r, logger, msg, err)
+	}
+}
+
+func serverError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, msg string, err error) {
+	logger.ErrorContext(r.Context(), msg, "error", err)
+	utils.ServerError(w, r)
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"errors"
+	"go-server/internal/store"
+	"go-server/internal/utils"
+	"go-server/internal/validator"
+	"go-server/middleware"
+	"log/slog"
+	"net/http"
+)
+
+type ExerciseHandler struct {
+	exerciseStore store.ExerciseStore
+	logger        *slog.Logger
+}
+
+func NewExerciseHandler(exerciseStore store.ExerciseStore, logger *slog.Logger) *ExerciseHandler {
+	return &ExerciseHandler{
+		exerciseStore: exerciseStore,
+		logger:        logger,
+	}
+}
+
+// requireOwner writes a problem response and returns false unless the current
+// user added the exercise. Shared catalog exercises cannot be changed, since
+// every user's entries, records and programs point at them.
+func (eh *ExerciseHandler) requireOwner(resWriter http.ResponseWriter, request *http.Request, exerciseID int64) bool {
+	exercise, err := eh.exerciseStore.GetExerciseByID(exerciseID)
+	if err != nil {
+		writeStoreError(resWriter, request, eh.logger, "GetExerciseByID failed", err)
+		return false
+	}
+
+	if exercise.UserID == nil || *exercise.UserID != middleware.GetUser(request).ID {
+		utils.Forbidden(resWriter, request, "you can only change exercises you created")
+		return false
+	}
+
+	return true
+}
+
+func (eh *ExerciseHandler) HandleListExercises(resWriter http.ResponseWriter, request *http.Request) {
+	exercises, err := eh.exerciseStore.ListExercises()
+	if err != nil {
+		writeStoreError(resWriter, request, eh.logger, "ListExercises failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"exercises": exercises})
+}
+
+func (eh *ExerciseHandler) HandleGetExerciseById(resWriter http.ResponseWriter, request *http.Request) {
+	exerciseId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	exercise, err := eh.exerciseStore.GetExerciseByID(exerciseId)
+	if err != nil {
+		writeStoreError(resWriter, request, eh.logger, "GetExerciseByID failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (eh *ExerciseHandler) HandleCreateExercise(resWriter http.ResponseWriter, request *http.Request) {
+	var exercise store.Exercise
+	err := utils.ReadJSON(resWriter, request, &exercise)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	if exercise.MovementType == "" {
+		exercise.MovementType = store.MovementTypeReps
+	}
+
+	v := validator.New()
+	if store.ValidateExercise(v, &exercise); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	userID := middleware.GetUser(request).ID
+	exercise.UserID = &userID
+	err = eh.exerciseStore.CreateExercise(&exercise)
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicateExercise) {
+			utils.WriteProblem(resWriter, request, duplicateExerciseProblem)
+			return
+		}
+		if errors.Is(err, store.ErrDuplicateAlias) {
+			utils.WriteProblem(resWriter, request, duplicateAliasProblem)
+			return
+		}
+		writeStoreError(resWriter, request, eh.logger, "CreateExercise failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusCreated, utils.Envelope{"exercise": exercise})
+}
+
+func (eh *ExerciseHandler) HandleUpdateExercise(resWriter http.ResponseWriter, request *http.Request) {
+	exerciseId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	if !eh.requireOwner(resWriter, request, exerciseId) {
+		return
+	}
+
+	var exercise store.Exercise
+	err = utils.ReadJSON(resWriter, request, &exercise)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	if exercise.MovementType == "" {
+		exercise.MovementType = store.MovementTypeReps
+	}
+
+	v := validator.New()
+	if store.ValidateExercise(v, &exercise); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	exercise.ID = int(exerciseId)
+	err = eh.exerciseStore.UpdateExercise(&exercise)
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicateExercise) {
+			utils.WriteProblem(resWriter, request, duplicateExerciseProblem)
+			return
+		}
+		if errors.Is(err, store.ErrDuplicateAlias) {
+			utils.WriteProblem(resWriter, request, duplicateAliasProblem)
+			return
+		}
+		if errors.Is(err, store.ErrExerciseInUse) {
+			utils.WriteError(resWriter, request, http.StatusConflict, utils.CodeConflict, exerciseInUseDetail)
+			return
+		}
+		writeStoreError(resWriter, request, eh.logger, "UpdateExercise failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (eh *ExerciseHandler) HandleDeleteExercise(resWriter http.ResponseWriter, request *http.Request) {
+	exerciseId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	if !eh.requireOwner(resWriter, request, exerciseId) {
+		return
+	}
+
+	err = eh.exerciseStore.DeleteExercise(exerciseId)
+	if err != nil {
+		if errors.Is(err, store.ErrExerciseInUse) {
+			utils.WriteError(resWriter, request, http.StatusConflict, utils.CodeConflict, exerciseInUseDetail)
+			return
+		}
+		writeStoreError(resWriter, request, eh.logger, "DeleteExercise failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"removedElement": exerciseId})
+}
+
+var duplicateExerciseProblem = utils.Problem{
+	Status: http.StatusConflict,
+	Code:   utils.CodeConflict,
+	Detail: "an exercise with this name already exists",
+	Errors: map[string]string{"name": "already exists"},
+}
+
+const exerciseInUseDetail = "other users have logged or planned this exercise, so it can no longer be changed"
+
+var duplicateAliasProblem = utils.Problem{
+	Status: http.StatusConflict,
+	Code:   utils.CodeConflict,
+	Detail: "an alias is already the name or alias of another exercise",
+	Errors: map[string]string{"aliases": "already used by another exercise"},
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"go-server/internal/store"
+	"go-server/middleware"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryExerciseStore keeps exercises in a map and records which writes
+// reached it.
+type memoryExerciseStore struct {
+	exercises map[int64]*store.Exercise
+	updated   []int
+	deleted   []int64
+}
+
+func (m *memoryExerciseStore) CreateExercise(exercise *store.Exercise) error {
+	exercise.ID = len(m.exercises) + 1
+	m.exercises[int64(exercise.ID)] = exercise
+	return nil
+}
+
+func (m *memoryExerciseStore) GetExerciseByID(id int64) (*store.Exercise, error) {
+	exercise, ok := m.exercises[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return exercise, nil
+}
+
+func (m *memoryExerciseStore) ListExercises() ([]*store.Exercise, error) {
+	return nil, nil
+}
+
+func (m *memoryExerciseStore) UpdateExercise(exercise *store.Exercise) error {
+	m.updated = append(m.updated, exercise.ID)
+	return nil
+}
+
+func (m *memoryExerciseStore) DeleteExercise(id int64) error {
+	m.deleted = append(m.deleted, id)
+	return nil
+}
+
+func (m *memoryExerciseStore) ResolveExercise(name string) (*store.Exercise, error) {
+	return nil, store.ErrNotFound
+}
+
+func TestExerciseWritesRequireOwner(t *testing.T) {
+	owner := 1
+	exercises := &memoryExerciseStore{exercises: map[int64]*store.Exercise{
+		1: {ID: 1, Name: "Bench Press", MovementType: store.MovementTypeReps},
+		2: {ID: 2, UserID: &owner, Name: "Cable Fly", MovementType: store.MovementTypeReps},
+	}}
+	handler := NewExerciseHandler(exercises, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	serve := func(userID int, method, target, body string) int {
+		router := chi.NewRouter()
+		router.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, middleware.SetUser(r, &store.User{ID: userID}))
+			})
+		})
+		router.Put("/exercises/{id}", handler.HandleUpdateExercise)
+		router.Delete("/exercises/{id}", handler.HandleDeleteExercise)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr.Code
+	}
+
+	rename := `{"name": "Renamed"}`
+	tests := []struct {
+		name   string
+		userID int
+		method string
+		target string
+		want   int
+	}{
+		{name: "rename shared exercise", userID: owner, method: http.MethodPut, target: "/exercises/1", want: http.StatusForbidden},
+		{name: "delete shared exercise", userID: owner, method: http.MethodDelete, target: "/exercises/1", want: http.StatusForbidden},
+		{name: "rename someone else's exercise", userID: 2, method: http.MethodPut, target: "/exercises/2", want: http.StatusForbidden},
+		{name: "delete someone else's exercise", userID: 2, method: http.MethodDelete, target: "/exercises/2", want: http.StatusForbidden},
+		{name: "missing exercise", userID: owner, method: http.MethodDelete, target: "/exercises/3", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serve(tt.userID, tt.method, tt.target, rename))
+		})
+	}
+	assert.Empty(t, exercises.updated)
+	assert.Empty(t, exercises.deleted)
+
+	assert.Equal(t, http.StatusOK, serve(owner, http.MethodPut, "/exercises/2", rename))
+	assert.Equal(t, http.StatusOK, serve(owner, http.MethodDelete, "/exercises/2", ""))
+	assert.Equal(t, []int{2}, exercises.updated)
+	assert.Equal(t, []int64{2}, exercises.deleted)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"go-server/internal/store"
+	"go-server/internal/utils"
+	"go-server/middleware"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type FollowHandler struct {
+	followStore store.FollowStore
+	userStore   store.UserStore
+	logger      *slog.Logger
+}
+
+func NewFollowHandler(followStore store.FollowStore, userStore store.UserStore, logger *slog.Logger) *FollowHandler {
+	return &FollowHandler{
+		followStore: followStore,
+		userStore:   userStore,
+		logger:      logger,
+	}
+}
+
+// followee resolves the {username} in the URL, rejecting the current user.
+func (fh *FollowHandler) followee(resWriter http.ResponseWriter, request *http.Request) (*store.User, bool) {
+	user, err := fh.userStore.GetUserByUsername(chi.URLParam(request, "username"))
+	if err != nil {
+		writeStoreError(resWriter, request, fh.logger, "loading user failed", err)
+		return nil, false
+	}
+
+	if user.ID == middleware.GetUser(request).ID {
+		utils.FailedValidation(resWriter, request, map[string]string{"username": "you cannot follow yourself"})
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (fh *FollowHandler) HandleFollow(resWriter http.ResponseWriter, request *http.Request) {
+	followee, ok := fh.followee(resWriter, request)
+	if !ok {
+		return
+	}
+
+	err := fh.followStore.Follow(middleware.GetUser(request).ID, followee.ID)
+	if err != nil {
+		writeStoreError(resWriter, request, fh.logger, "Follow failed", err)
+		return
+	}
+
+	resWriter.WriteHeader(http.StatusNoContent)
+}
+
+func (fh *FollowHandler) HandleUnfollow(resWriter http.ResponseWriter, request *http.Request) {
+	followee, ok := fh.followee(resWriter, request)
+	if !ok {
+		return
+	}
+
+	err := fh.followStore.Unfollow(middleware.GetUser(request).ID, followee.ID)
+	if err != nil {
+		writeStoreError(resWriter, request, fh.logger, "Unfollow failed", err)
+		return
+	}
+
+	resWriter.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"context"
+	"go-server/internal/mailer"
+	"go-server/internal/worker"
+	"log/slog"
+)
+
+// sendMailInBackground delivers the message without blocking the response,
+// which also keeps response times from revealing whether an account exists.
+func sendMailInBackground(workers *worker.Group, m mailer.Mailer, logger *slog.Logger, message mailer.Message) {
+	workers.Go("send email", func(ctx context.Context) {
+		if err := m.Send(message); err != nil {
+			logger.Error("sending email failed", "subject", message.Subject, "error", err)
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"go-server/internal/config"
+	"go-server/internal/mailer"
+	"go-server/internal/store"
+	"go-server/internal/tokens"
+	"go-server/internal/utils"
+	"go-server/internal/validator"
+	"go-server/internal/worker"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type PasswordResetHandler struct {
+	userStore  store.UserStore
+	tokenStore store.TokenStore
+	mailer     mailer.Mailer
+	workers    *worker.Group
+	authConfig config.AuthConfig
+	logger     *slog.Logger
+}
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func NewPasswordResetHandler(userStore store.UserStore, tokenStore store.TokenStore, mailer mailer.Mailer, workers *worker.Group, authConfig config.AuthConfig, logger *slog.Logger) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		mailer:     mailer,
+		workers:    workers,
+		authConfig: authConfig,
+		logger:     logger,
+	}
+}
+
+// HandleRequestPasswordReset always answers 202 so the endpoint cannot be
+// used to find out which emails are registered.
+func (ph *PasswordResetHandler) HandleRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req requestPasswordResetRequest
+	err := utils.ReadJSON(w, r, &req)
+	if err != nil {
+		utils.MalformedBody(w, r, err)
+		return
+	}
+	v := validator.New()
+	if v.Check(req.Email != "", "email", "is required"); !v.Valid() {
+		utils.FailedValidation(w, r, v.Errors)
+		return
+	}
+
+	accepted := utils.Envelope{"message": "if the email is registered, reset instructions have been sent"}
+
+	user, err := ph.userStore.GetUserByEmail(req.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.WriterJSON(w, http.StatusAccepted, accepted)
+		return
+	}
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "looking up user for password reset failed", "error", err)
+		utils.WriterJSON(w, http.StatusAccepted, accepted)
+		return
+	}
+
+	err = ph.tokenStore.DeleteAllTokensForUser(user.ID, tokens.ScopePasswordReset)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "deleting previous password reset tokens failed", "error", err)
+		utils.WriterJSON(w, http.StatusAccepted, accepted)
+		return
+	}
+
+	token, err := ph.tokenStore.CreateNewToken(user.ID, ph.authConfig.PasswordResetTokenTTL, tokens.ScopePasswordReset)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "creating password reset token failed", "error", err)
+		utils.WriterJSON(w, http.StatusAccepted, accepted)
+		return
+	}
+
+	message := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the following token to reset your password:\n\n%s\n\nThe token expires at %s. If you did not request a reset you can ignore this email.\n",
+			user.Username, token.PlainText, token.Expiry.Format(time.RFC1123),
+		),
+	}
+
+	sendMailInBackground(ph.workers, ph.mailer, ph.logger, message)
+
+	utils.WriterJSON(w, http.StatusAccepted, accepted)
+}
+
+func (ph *PasswordResetHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	err := utils.ReadJSON(w, r, &req)
+	if err != nil {
+		utils.MalformedBody(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(req.Token != "", "token", "is required")
+	if store.ValidatePasswordPlaintext(v, "password", req.Password); !v.Valid() {
+		utils.FailedValidation(w, r, v.Errors)
+		return
+	}
+
+	user, err := ph.userStore.GetUserToken(tokens.ScopePasswordReset, req.Token)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.FailedValidation(w, r, map[string]string{"token": "invalid or expired password reset token"})
+		return
+	}
+	if err != nil {
+		serverError(w, r, ph.logger, "looking up password reset token failed", err)
+		return
+	}
+
+	err = user.PasswordHash.Set(req.Password)
+	if err != nil {
+		serverError(w, r, ph.logger, "hashing password failed", err)
+		return
+	}
+
+	err = ph.userStore.UpdatePassword(user, "")
+	if err != nil {
+		writeStoreError(w, r, ph.logger, "updating password failed", err)
+		return
+	}
+
+	utils.WriterJSON(w, http.StatusOK, utils.Envelope{"message": "password has been reset"})
+}
@@ -0,0 +1,422 @@
+package api
+
+import (
+	"errors"
+	"go-server/internal/metrics"
+	"go-server/internal/store"
+	"go-server/internal/utils"
+	"go-server/internal/validator"
+	"go-server/middleware"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// defaultScheduleDays is how far ahead the schedule looks when no to date is
+// given.
+const defaultScheduleDays = 14
+
+type ProgramHandler struct {
+	programStore  store.ProgramStore
+	templateStore store.TemplateStore
+	workoutStore  store.WorkoutStore
+	metrics       *metrics.Metrics
+	logger        *slog.Logger
+}
+
+func NewProgramHandler(programStore store.ProgramStore, templateStore store.TemplateStore, workoutStore store.WorkoutStore, metrics *metrics.Metrics, logger *slog.Logger) *ProgramHandler {
+	return &ProgramHandler{
+		programStore:  programStore,
+		templateStore: templateStore,
+		workoutStore:  workoutStore,
+		metrics:       metrics,
+		logger:        logger,
+	}
+}
+
+type enrollRequest struct {
+	StartDate     string              `json:"start_date"`
+	TrainingMaxes []store.TrainingMax `json:"training_maxes"`
+}
+
+type logSessionRequest struct {
+	WorkoutID int `json:"workout_id"`
+}
+
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// visibleProgram loads the program in the URL. Private programs of other
+// users are reported as missing.
+func (ph *ProgramHandler) visibleProgram(resWriter http.ResponseWriter, request *http.Request) (*store.Program, bool) {
+	programId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return nil, false
+	}
+
+	program, err := ph.programStore.GetProgramByID(programId)
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "GetProgramByID failed", err)
+		return nil, false
+	}
+
+	if !program.Public && program.UserID != middleware.GetUser(request).ID {
+		utils.NotFound(resWriter, request, "the requested resource could not be found")
+		return nil, false
+	}
+
+	return program, true
+}
+
+// ownEnrollment loads the enrollment in the URL together with its program.
+// Other users' enrollments are reported as missing.
+func (ph *ProgramHandler) ownEnrollment(resWriter http.ResponseWriter, request *http.Request) (*store.Enrollment, *store.Program, bool) {
+	enrollmentId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return nil, nil, false
+	}
+
+	enrollment, err := ph.programStore.GetEnrollmentByID(enrollmentId)
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "GetEnrollmentByID failed", err)
+		return nil, nil, false
+	}
+
+	if enrollment.UserID != middleware.GetUser(request).ID {
+		utils.NotFound(resWriter, request, "the requested resource could not be found")
+		return nil, nil, false
+	}
+
+	program, err := ph.programStore.GetProgramByID(int64(enrollment.ProgramID))
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "GetProgramByID failed", err)
+		return nil, nil, false
+	}
+
+	return enrollment, program, true
+}
+
+// enrollmentSession resolves the sessionId in the URL against the program.
+func (ph *ProgramHandler) enrollmentSession(resWriter http.ResponseWriter, request *http.Request, program *store.Program) (*store.ProgramSession, bool) {
+	sessionId, err := utils.ReadIDParam(request, "sessionId")
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return nil, false
+	}
+
+	session, ok := program.Session(int(sessionId))
+	if !ok {
+		utils.NotFound(resWriter, request, "the requested resource could not be found")
+		return nil, false
+	}
+
+	return session, true
+}
+
+func (ph *ProgramHandler) HandleListPrograms(resWriter http.ResponseWriter, request *http.Request) {
+	programs, err := ph.programStore.ListPrograms(middleware.GetUser(request).ID)
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "ListPrograms failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"programs": programs})
+}
+
+func (ph *ProgramHandler) HandleGetProgram(resWriter http.ResponseWriter, request *http.Request) {
+	program, ok := ph.visibleProgram(resWriter, request)
+	if !ok {
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"program": program})
+}
+
+func (ph *ProgramHandler) HandleCreateProgram(resWriter http.ResponseWriter, request *http.Request) {
+	var program store.Program
+	err := utils.ReadJSON(resWriter, request, &program)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	v := validator.New()
+	if store.ValidateProgram(v, &program); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	program.UserID = middleware.GetUser(request).ID
+
+	err = ph.programStore.CreateProgram(&program)
+	if err != nil {
+		if errors.Is(err, store.ErrUnknownTemplate) {
+			utils.FailedValidation(resWriter, request, map[string]string{"sessions": "must only use your own templates"})
+			return
+		}
+		writeStoreError(resWriter, request, ph.logger, "CreateProgram failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusCreated, utils.Envelope{"program": program})
+}
+
+// HandleDeleteProgram removes the program once nobody else follows it. The
+// author's own enrollments go with it; workouts logged against its sessions
+// are kept.
+func (ph *ProgramHandler) HandleDeleteProgram(resWriter http.ResponseWriter, request *http.Request) {
+	program, ok := ph.visibleProgram(resWriter, request)
+	if !ok {
+		return
+	}
+
+	if program.UserID != middleware.GetUser(request).ID {
+		utils.Forbidden(resWriter, request, "you can only delete your own programs")
+		return
+	}
+
+	err := ph.programStore.DeleteProgram(int64(program.ID))
+	if err != nil {
+		if errors.Is(err, store.ErrProgramInUse) {
+			utils.WriteError(resWriter, request, http.StatusConflict, utils.CodeConflict, "other users are enrolled in the program, it can no longer be deleted")
+			return
+		}
+		writeStoreError(resWriter, request, ph.logger, "DeleteProgram failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"removedElement": program.ID})
+}
+
+func (ph *ProgramHandler) HandleEnroll(resWriter http.ResponseWriter, request *http.Request) {
+	program, ok := ph.visibleProgram(resWriter, request)
+	if !ok {
+		return
+	}
+
+	var input enrollRequest
+	err := utils.ReadJSON(resWriter, request, &input)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	enrollment := store.Enrollment{
+		ProgramID:     program.ID,
+		UserID:        middleware.GetUser(request).ID,
+		TrainingMaxes: input.TrainingMaxes,
+	}
+	if enrollment.TrainingMaxes == nil {
+		enrollment.TrainingMaxes = []store.TrainingMax{}
+	}
+
+	v := validator.New()
+	if input.StartDate != "" {
+		enrollment.StartDate, err = time.Parse(time.DateOnly, input.StartDate)
+		v.Check(err == nil, "start_date", "must be a YYYY-MM-DD date")
+	}
+	if store.ValidateEnrollment(v, &enrollment); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	err = ph.programStore.CreateEnrollment(&enrollment)
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "CreateEnrollment failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusCreated, utils.Envelope{"enrollment": enrollment})
+}
+
+func (ph *ProgramHandler) HandleListEnrollments(resWriter http.ResponseWriter, request *http.Request) {
+	enrollments, err := ph.programStore.ListEnrollments(middleware.GetUser(request).ID)
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "ListEnrollments failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"enrollments": enrollments})
+}
+
+// HandleGetEnrollment returns the enrollment with its full plan and the
+// adherence so far.
+func (ph *ProgramHandler) HandleGetEnrollment(resWriter http.ResponseWriter, request *http.Request) {
+	enrollment, program, ok := ph.ownEnrollment(resWriter, request)
+	if !ok {
+		return
+	}
+
+	completed, err := ph.programStore.CompletedSessions(int64(enrollment.ID))
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "CompletedSessions failed", err)
+		return
+	}
+
+	planned := store.PlanSessions(program, enrollment, completed)
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{
+		"enrollment": enrollment,
+		"sessions":   planned,
+		"adherence":  store.ComputeAdherence(planned, today()),
+	})
+}
+
+func (ph *ProgramHandler) HandleDeleteEnrollment(resWriter http.ResponseWriter, request *http.Request) {
+	enrollment, _, ok := ph.ownEnrollment(resWriter, request)
+	if !ok {
+		return
+	}
+
+	err := ph.programStore.DeleteEnrollment(int64(enrollment.ID))
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "DeleteEnrollment failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"removedElement": enrollment.ID})
+}
+
+// HandleGetSchedule lists the planned sessions of all the user's enrollments
+// between from and to, both inclusive, defaulting to the next two weeks.
+func (ph *ProgramHandler) HandleGetSchedule(resWriter http.ResponseWriter, request *http.Request) {
+	from, err := utils.ReadQueryTime(request, "from")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+	if from == nil {
+		start := today()
+		from = &start
+	}
+
+	to, err := utils.ReadQueryTime(request, "to")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+	if to == nil {
+		end := from.AddDate(0, 0, defaultScheduleDays)
+		to = &end
+	}
+	if to.Before(*from) {
+		utils.BadRequest(resWriter, request, "to must not be before from")
+		return
+	}
+
+	enrollments, err := ph.programStore.ListEnrollments(middleware.GetUser(request).ID)
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "ListEnrollments failed", err)
+		return
+	}
+
+	schedule := []store.PlannedSession{}
+	for _, enrollment := range enrollments {
+		program, err := ph.programStore.GetProgramByID(int64(enrollment.ProgramID))
+		if err != nil {
+			writeStoreError(resWriter, request, ph.logger, "GetProgramByID failed", err)
+			return
+		}
+
+		completed, err := ph.programStore.CompletedSessions(int64(enrollment.ID))
+		if err != nil {
+			writeStoreError(resWriter, request, ph.logger, "CompletedSessions failed", err)
+			return
+		}
+
+		for _, session := range store.PlanSessions(program, enrollment, completed) {
+			if !session.Date.Before(*from) && !session.Date.After(*to) {
+				schedule = append(schedule, session)
+			}
+		}
+	}
+
+	slices.SortStableFunc(schedule, func(a, b store.PlannedSession) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"schedule": schedule})
+}
+
+// HandleStartSession creates a workout from the session's template with the
+// prescribed weights filled in and records it as fulfilling the session.
+func (ph *ProgramHandler) HandleStartSession(resWriter http.ResponseWriter, request *http.Request) {
+	enrollment, program, ok := ph.ownEnrollment(resWriter, request)
+	if !ok {
+		return
+	}
+
+	session, ok := ph.enrollmentSession(resWriter, request, program)
+	if !ok {
+		return
+	}
+
+	template, err := ph.templateStore.GetTemplateByID(int64(session.TemplateID))
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "GetTemplateByID failed", err)
+		return
+	}
+
+	workout, err := ph.programStore.StartSession(template, session, enrollment)
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "StartSession failed", err)
+		return
+	}
+	ph.metrics.WorkoutCreated()
+
+	resWriter.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriterJSON(resWriter, http.StatusCreated, utils.Envelope{"workout": workout})
+}
+
+// HandleLogSession links an already logged workout to the session it
+// fulfilled, replacing any workout linked before.
+func (ph *ProgramHandler) HandleLogSession(resWriter http.ResponseWriter, request *http.Request) {
+	enrollment, program, ok := ph.ownEnrollment(resWriter, request)
+	if !ok {
+		return
+	}
+
+	session, ok := ph.enrollmentSession(resWriter, request, program)
+	if !ok {
+		return
+	}
+
+	var input logSessionRequest
+	err := utils.ReadJSON(resWriter, request, &input)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	workout, err := ph.workoutStore.GetWorkoutByID(int64(input.WorkoutID))
+	if err != nil || workout.UserID != enrollment.UserID {
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			writeStoreError(resWriter, request, ph.logger, "GetWorkoutByID failed", err)
+			return
+		}
+		utils.FailedValidation(resWriter, request, map[string]string{"workout_id": "must be one of your workouts"})
+		return
+	}
+
+	err = ph.programStore.LogSession(int64(enrollment.ID), int64(session.ID), workout.ID)
+	if err != nil {
+		writeStoreError(resWriter, request, ph.logger, "LogSession failed", err)
+		return
+	}
+
+	planned := store.PlannedSession{
+		EnrollmentID: enrollment.ID,
+		ProgramID:    program.ID,
+		SessionID:    session.ID,
+		Week:         session.Week,
+		Day:          session.Day,
+		Date:         enrollment.SessionDate(*session),
+		TemplateID:   session.TemplateID,
+		WorkoutID:    &workout.ID,
+	}
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"session": planned})
+}
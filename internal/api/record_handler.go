@@ -0,0 +1,226 @@
+package api
+
+import (
+	"go-server/internal/store"
+	"go-server/internal/strength"
+	"go-server/internal/utils"
+	"go-server/internal/validator"
+	"go-server/middleware"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	// progressionHistorySessions is how many past sessions of an exercise
+	// are considered when suggesting the next target.
+	progressionHistorySessions = 10
+	maxProgressWindow          = 30
+)
+
+type RecordHandler struct {
+	recordStore store.RecordStore
+	logger      *slog.Logger
+}
+
+func NewRecordHandler(recordStore store.RecordStore, logger *slog.Logger) *RecordHandler {
+	return &RecordHandler{
+		recordStore: recordStore,
+		logger:      logger,
+	}
+}
+
+func (rh *RecordHandler) HandleGetMyRecords(resWriter http.ResponseWriter, request *http.Request) {
+	currentUser := middleware.GetUser(request)
+
+	records, err := rh.recordStore.GetRecordsForUser(currentUser.ID)
+	if err != nil {
+		writeStoreError(resWriter, request, rh.logger, "GetRecordsForUser failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"records": records})
+}
+
+func (rh *RecordHandler) HandleGetExerciseRecords(resWriter http.ResponseWriter, request *http.Request) {
+	exerciseId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	currentUser := middleware.GetUser(request)
+
+	records, err := rh.recordStore.GetRecordsForExercise(currentUser.ID, exerciseId)
+	if err != nil {
+		writeStoreError(resWriter, request, rh.logger, "GetRecordsForExercise failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"records": records})
+}
+
+// HandleGetExerciseProgress returns one point per session of the exercise
+// with the estimated one-rep max from the chosen formula, the best set and
+// the volume. window > 1 adds a moving average of the estimate.
+func (rh *RecordHandler) HandleGetExerciseProgress(resWriter http.ResponseWriter, request *http.Request) {
+	exerciseId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	formulaName := request.URL.Query().Get("formula")
+	if formulaName == "" {
+		formulaName = strength.FormulaEpley
+	}
+	formula, ok := strength.FormulaByName(formulaName)
+	if !ok {
+		utils.BadRequest(resWriter, request, "formula must be epley, brzycki or lombardi")
+		return
+	}
+
+	window, err := utils.ReadQueryInt(request, "window")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+	if window == nil {
+		window = new(int)
+	}
+	if !validator.Between(*window, 0, maxProgressWindow) {
+		utils.BadRequest(resWriter, request, "window must be between 0 and 30")
+		return
+	}
+
+	from, err := utils.ReadQueryTime(request, "from")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+
+	to, err := utils.ReadQueryTime(request, "to")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+
+	if from != nil && to != nil && !from.Before(*to) {
+		utils.BadRequest(resWriter, request, "from must be before to")
+		return
+	}
+
+	sessions, err := rh.recordStore.GetExerciseSessions(middleware.GetUser(request).ID, exerciseId, from, to)
+	if err != nil {
+		writeStoreError(resWriter, request, rh.logger, "GetExerciseSessions failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{
+		"formula":  formulaName,
+		"progress": strength.Progress(sessions, formula, *window),
+	})
+}
+
+// HandleGetNextTarget suggests the load for the next session of an exercise
+// from the user's recent history. The target is null until the exercise has
+// been logged with reps and weight.
+func (rh *RecordHandler) HandleGetNextTarget(resWriter http.ResponseWriter, request *http.Request) {
+	exerciseId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	ruleName := request.URL.Query().Get("rule")
+	if ruleName == "" {
+		ruleName = strength.RuleLinear
+	}
+
+	rule, errs, err := readProgressionRule(request, ruleName)
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+	if errs != nil {
+		utils.FailedValidation(resWriter, request, errs)
+		return
+	}
+
+	history, err := rh.recordStore.GetExerciseHistory(middleware.GetUser(request).ID, exerciseId, progressionHistorySessions)
+	if err != nil {
+		writeStoreError(resWriter, request, rh.logger, "GetExerciseHistory failed", err)
+		return
+	}
+
+	var target *strength.Target
+	if len(history) > 0 {
+		next := rule.Next(history)
+		target = &next
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"rule": ruleName, "target": target})
+}
+
+// readProgressionRule builds the named rule from the query string, filling
+// in defaults for parameters that are not given. Unparseable parameters are
+// returned as err, out of range ones as validation errors.
+func readProgressionRule(request *http.Request, ruleName string) (strength.ProgressionRule, map[string]string, error) {
+	ints := map[string]int{"reps": 5, "min_reps": 8, "max_reps": 12, "deload_after": 3}
+	for key := range ints {
+		value, err := utils.ReadQueryInt(request, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if value != nil {
+			ints[key] = *value
+		}
+	}
+
+	floats := map[string]float64{"increment": 2.5, "deload_percent": 10, "rpe": 8}
+	for key := range floats {
+		value, err := utils.ReadQueryFloat(request, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if value != nil {
+			floats[key] = *value
+		}
+	}
+
+	v := validator.New()
+	v.Check(validator.PermittedValue(ruleName, strength.RuleLinear, strength.RuleDoubleProgression, strength.RuleRPE), "rule", "must be linear, double_progression or rpe")
+	v.Check(validator.Between(ints["reps"], 1, 100), "reps", "must be between 1 and 100")
+	v.Check(validator.Between(ints["min_reps"], 1, 100), "min_reps", "must be between 1 and 100")
+	v.Check(validator.Between(ints["max_reps"], ints["min_reps"], 100), "max_reps", "must be between min_reps and 100")
+	v.Check(validator.Between(ints["deload_after"], 1, 10), "deload_after", "must be between 1 and 10")
+	v.Check(floats["increment"] > 0 && floats["increment"] <= 50, "increment", "must be above 0 and at most 50")
+	v.Check(validator.Between(floats["deload_percent"], 1, 50), "deload_percent", "must be between 1 and 50")
+	v.Check(validator.Between(floats["rpe"], 6, 10), "rpe", "must be between 6 and 10")
+	if !v.Valid() {
+		return nil, v.Errors, nil
+	}
+
+	switch ruleName {
+	case strength.RuleDoubleProgression:
+		return strength.DoubleProgression{
+			MinReps:       ints["min_reps"],
+			MaxReps:       ints["max_reps"],
+			Increment:     floats["increment"],
+			DeloadAfter:   ints["deload_after"],
+			DeloadPercent: floats["deload_percent"],
+		}, nil, nil
+	case strength.RuleRPE:
+		return strength.RPEBased{
+			Reps:      ints["reps"],
+			TargetRPE: floats["rpe"],
+			Increment: floats["increment"],
+		}, nil, nil
+	default:
+		return strength.Linear{
+			Reps:          ints["reps"],
+			Increment:     floats["increment"],
+			DeloadAfter:   ints["deload_after"],
+			DeloadPercent: floats["deload_percent"],
+		}, nil, nil
+	}
+}
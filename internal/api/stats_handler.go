@@ -0,0 +1,132 @@
+package api
+
+import (
+	"go-server/internal/store"
+	"go-server/internal/trainingload"
+	"go-server/internal/utils"
+	"go-server/middleware"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultStatsDays is the range covered when no from date is given.
+	defaultStatsDays = 90
+	maxLoadDays      = 366
+)
+
+type StatsHandler struct {
+	statsStore     store.StatsStore
+	loadThresholds trainingload.Thresholds
+	logger         *slog.Logger
+}
+
+func NewStatsHandler(statsStore store.StatsStore, loadThresholds trainingload.Thresholds, logger *slog.Logger) *StatsHandler {
+	return &StatsHandler{
+		statsStore:     statsStore,
+		loadThresholds: loadThresholds,
+		logger:         logger,
+	}
+}
+
+// HandleGetMyStats aggregates the user's workouts created from the from date
+// up to, but excluding, the to date. to defaults to now and from to 90 days
+// before it.
+func (sh *StatsHandler) HandleGetMyStats(resWriter http.ResponseWriter, request *http.Request) {
+	filter := store.StatsFilter{
+		UserID: middleware.GetUser(request).ID,
+		Bucket: request.URL.Query().Get("bucket"),
+	}
+
+	if filter.Bucket == "" {
+		filter.Bucket = store.StatsBucketWeek
+	}
+	if !store.ValidStatsBucket(filter.Bucket) {
+		utils.BadRequest(resWriter, request, "bucket must be week or month")
+		return
+	}
+
+	to, err := utils.ReadQueryTime(request, "to")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+	filter.To = time.Now().UTC()
+	if to != nil {
+		filter.To = *to
+	}
+
+	from, err := utils.ReadQueryTime(request, "from")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+	filter.From = filter.To.AddDate(0, 0, -defaultStatsDays)
+	if from != nil {
+		filter.From = *from
+	}
+
+	if !filter.From.Before(filter.To) {
+		utils.BadRequest(resWriter, request, "from must be before to")
+		return
+	}
+
+	stats, err := sh.statsStore.GetStats(filter)
+	if err != nil {
+		writeStoreError(resWriter, request, sh.logger, "GetStats failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"stats": stats})
+}
+
+// HandleGetMyLoad returns the user's daily training load with rolling acute
+// and chronic loads, ACWR, monotony and strain for every day from the from
+// date to the to date, both inclusive. The range defaults to the last four
+// weeks. Days whose ACWR leaves the configured band are flagged.
+func (sh *StatsHandler) HandleGetMyLoad(resWriter http.ResponseWriter, request *http.Request) {
+	to, err := utils.ReadQueryTime(request, "to")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+	if to == nil {
+		today := time.Now().UTC()
+		to = &today
+	}
+
+	from, err := utils.ReadQueryTime(request, "from")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+	if from == nil {
+		start := to.AddDate(0, 0, -(trainingload.ChronicDays - 1))
+		from = &start
+	}
+
+	fromDay := from.UTC().Truncate(24 * time.Hour)
+	toDay := to.UTC().Truncate(24 * time.Hour)
+	if toDay.Before(fromDay) {
+		utils.BadRequest(resWriter, request, "to must not be before from")
+		return
+	}
+	if toDay.Sub(fromDay) >= maxLoadDays*24*time.Hour {
+		utils.BadRequest(resWriter, request, "the range cannot exceed 366 days")
+		return
+	}
+
+	// the chronic load of the first day looks back four weeks
+	queryFrom := fromDay.AddDate(0, 0, -(trainingload.ChronicDays - 1))
+	days, err := sh.statsStore.GetDailyLoads(middleware.GetUser(request).ID, queryFrom, toDay.AddDate(0, 0, 1))
+	if err != nil {
+		writeStoreError(resWriter, request, sh.logger, "GetDailyLoads failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{
+		"thresholds": sh.loadThresholds,
+		"load":       trainingload.Compute(days, fromDay, toDay, sh.loadThresholds),
+	})
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"errors"
+	"go-server/internal/metrics"
+	"go-server/internal/store"
+	"go-server/internal/tokens"
+	"go-server/internal/utils"
+	"go-server/internal/validator"
+	"go-server/middleware"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type TemplateHandler struct {
+	templateStore store.TemplateStore
+	workoutStore  store.WorkoutStore
+	metrics       *metrics.Metrics
+	logger        *slog.Logger
+}
+
+func NewTemplateHandler(templateStore store.TemplateStore, workoutStore store.WorkoutStore, metrics *metrics.Metrics, logger *slog.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		templateStore: templateStore,
+		workoutStore:  workoutStore,
+		metrics:       metrics,
+		logger:        logger,
+	}
+}
+
+// ownTemplate loads the template in the URL. Other users' templates are
+// reported as missing; shared templates are reached through their code.
+func (th *TemplateHandler) ownTemplate(resWriter http.ResponseWriter, request *http.Request) (*store.WorkoutTemplate, bool) {
+	templateId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return nil, false
+	}
+
+	template, err := th.templateStore.GetTemplateByID(templateId)
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "GetTemplateByID failed", err)
+		return nil, false
+	}
+
+	if template.UserID != middleware.GetUser(request).ID {
+		utils.NotFound(resWriter, request, "the requested resource could not be found")
+		return nil, false
+	}
+
+	return template, true
+}
+
+func (th *TemplateHandler) HandleListTemplates(resWriter http.ResponseWriter, request *http.Request) {
+	templates, err := th.templateStore.ListTemplates(middleware.GetUser(request).ID)
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "ListTemplates failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"templates": templates})
+}
+
+func (th *TemplateHandler) HandleGetTemplate(resWriter http.ResponseWriter, request *http.Request) {
+	template, ok := th.ownTemplate(resWriter, request)
+	if !ok {
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleGetSharedTemplate(resWriter http.ResponseWriter, request *http.Request) {
+	template, err := th.templateStore.GetTemplateByShareCode(chi.URLParam(request, "code"))
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "GetTemplateByShareCode failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleCreateTemplate(resWriter http.ResponseWriter, request *http.Request) {
+	var template store.WorkoutTemplate
+	err := utils.ReadJSON(resWriter, request, &template)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	v := validator.New()
+	if store.ValidateTemplate(v, &template); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	template.UserID = middleware.GetUser(request).ID
+	template.ShareCode = nil
+
+	err = th.templateStore.CreateTemplate(&template)
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "CreateTemplate failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusCreated, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleUpdateTemplate(resWriter http.ResponseWriter, request *http.Request) {
+	existing, ok := th.ownTemplate(resWriter, request)
+	if !ok {
+		return
+	}
+
+	var template store.WorkoutTemplate
+	err := utils.ReadJSON(resWriter, request, &template)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	v := validator.New()
+	if store.ValidateTemplate(v, &template); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	template.ID = existing.ID
+	err = th.templateStore.UpdateTemplate(&template)
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "UpdateTemplate failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleDeleteTemplate(resWriter http.ResponseWriter, request *http.Request) {
+	template, ok := th.ownTemplate(resWriter, request)
+	if !ok {
+		return
+	}
+
+	err := th.templateStore.DeleteTemplate(int64(template.ID))
+	if err != nil {
+		if errors.Is(err, store.ErrTemplateInUse) {
+			utils.WriteError(resWriter, request, http.StatusConflict, utils.CodeConflict, "the template is used by a program, delete the program first")
+			return
+		}
+		writeStoreError(resWriter, request, th.logger, "DeleteTemplate failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"removedElement": template.ID})
+}
+
+// HandleShareTemplate publishes the template under a new share code. Calling
+// it again rotates the code, which revokes the previous link.
+func (th *TemplateHandler) HandleShareTemplate(resWriter http.ResponseWriter, request *http.Request) {
+	template, ok := th.ownTemplate(resWriter, request)
+	if !ok {
+		return
+	}
+
+	code, err := tokens.GenerateShareCode()
+	if err != nil {
+		serverError(resWriter, request, th.logger, "generating share code failed", err)
+		return
+	}
+
+	err = th.templateStore.SetTemplateShareCode(int64(template.ID), &code)
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "SetTemplateShareCode failed", err)
+		return
+	}
+
+	template.ShareCode = &code
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleUnshareTemplate(resWriter http.ResponseWriter, request *http.Request) {
+	template, ok := th.ownTemplate(resWriter, request)
+	if !ok {
+		return
+	}
+
+	err := th.templateStore.SetTemplateShareCode(int64(template.ID), nil)
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "SetTemplateShareCode failed", err)
+		return
+	}
+
+	resWriter.WriteHeader(http.StatusNoContent)
+}
+
+func (th *TemplateHandler) HandleStartWorkout(resWriter http.ResponseWriter, request *http.Request) {
+	template, ok := th.ownTemplate(resWriter, request)
+	if !ok {
+		return
+	}
+
+	th.startWorkout(resWriter, request, template)
+}
+
+func (th *TemplateHandler) HandleStartSharedWorkout(resWriter http.ResponseWriter, request *http.Request) {
+	template, err := th.templateStore.GetTemplateByShareCode(chi.URLParam(request, "code"))
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "GetTemplateByShareCode failed", err)
+		return
+	}
+
+	th.startWorkout(resWriter, request, template)
+}
+
+func (th *TemplateHandler) startWorkout(resWriter http.ResponseWriter, request *http.Request, template *store.WorkoutTemplate) {
+	workout, err := th.workoutStore.CreateWorkout(template.NewWorkout(middleware.GetUser(request).ID))
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "CreateWorkout failed", err)
+		return
+	}
+	th.metrics.WorkoutCreated()
+
+	resWriter.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriterJSON(resWriter, http.StatusCreated, utils.Envelope{"workout": workout})
+}
+
+func (th *TemplateHandler) HandleSaveWorkoutAsTemplate(resWriter http.ResponseWriter, request *http.Request) {
+	workoutId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	workout, err := th.workoutStore.GetWorkoutByID(workoutId)
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "GetWorkoutByID failed", err)
+		return
+	}
+
+	if workout.UserID != middleware.GetUser(request).ID {
+		utils.Forbidden(resWriter, request, "you can only save your own workouts as templates")
+		return
+	}
+
+	template := store.TemplateFromWorkout(workout)
+	err = th.templateStore.CreateTemplate(template)
+	if err != nil {
+		writeStoreError(resWriter, request, th.logger, "CreateTemplate failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusCreated, utils.Envelope{"template": template})
+}
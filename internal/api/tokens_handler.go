@@ -1,19 +1,22 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
+	"go-server/internal/config"
 	"go-server/internal/store"
 	"go-server/internal/tokens"
 	"go-server/internal/utils"
-	"log"
+	"go-server/internal/validator"
+	"go-server/middleware"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 type TokensHandler struct {
 	tokenStore store.TokenStore
 	userStore  store.UserStore
-	logger     *log.Logger
+	authConfig config.AuthConfig
+	logger     *slog.Logger
 }
 
 type createTokenRequest struct {
@@ -21,49 +24,144 @@ type createTokenRequest struct {
 	Password string `json:"password"`
 }
 
-func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, logger *log.Logger) *TokensHandler {
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, authConfig config.AuthConfig, logger *slog.Logger) *TokensHandler {
 	return &TokensHandler{
 		tokenStore: tokenStore,
 		userStore:  userStore,
+		authConfig: authConfig,
 		logger:     logger,
 	}
 }
 
 func (t *TokensHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
 	var req createTokenRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := utils.ReadJSON(w, r, &req)
 	if err != nil {
-		t.logger.Fatalf("HandleCreateToken error during decoding body %v", err)
-		utils.WriterJSON(w, http.StatusBadRequest, utils.Envelope{"error": "error with request body, check it"})
+		utils.MalformedBody(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(req.Email != "", "email", "is required")
+	v.Check(req.Password != "", "password", "is required")
+	if !v.Valid() {
+		utils.FailedValidation(w, r, v.Errors)
 		return
 	}
 
 	user, err := t.userStore.GetUserByEmail(req.Email)
-	if err != nil || user == nil {
-		t.logger.Fatalf("HandleCreateToken cannot find user %v", err)
-		utils.WriterJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+	if errors.Is(err, store.ErrNotFound) {
+		invalidCredentials(w, r)
+		return
+	}
+	if err != nil {
+		serverError(w, r, t.logger, "loading user failed", err)
 		return
 	}
 
 	passwordDoMatches, err := user.PasswordHash.Matches(req.Password)
-	if err != nil || !passwordDoMatches {
-		t.logger.Fatalf("HandleCreateToken error during password matching %v", err)
-		utils.WriterJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+	if err != nil {
+		serverError(w, r, t.logger, "matching password failed", err)
 		return
 	}
 
 	if !passwordDoMatches {
-		t.logger.Fatalf("HandleCreateToken password is not matching %v", err)
-		utils.WriterJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
+		invalidCredentials(w, r)
+		return
+	}
+
+	pair, err := t.tokenStore.CreateTokenPair(user.ID, t.authConfig.AccessTokenTTL, t.authConfig.RefreshTokenTTL, requestClient(r))
+	if err != nil {
+		serverError(w, r, t.logger, "creating token pair failed", err)
+		return
+	}
+
+	utils.WriterJSON(w, http.StatusCreated, utils.Envelope{"auth_token": pair.Access, "refresh_token": pair.Refresh})
+}
+
+func (t *TokensHandler) HandleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req refreshTokenRequest
+	err := utils.ReadJSON(w, r, &req)
+	if err != nil {
+		utils.MalformedBody(w, r, err)
+		return
+	}
+	v := validator.New()
+	if v.Check(req.RefreshToken != "", "refresh_token", "is required"); !v.Valid() {
+		utils.FailedValidation(w, r, v.Errors)
+		return
+	}
+
+	pair, err := t.tokenStore.RotateRefreshToken(req.RefreshToken, t.authConfig.AccessTokenTTL, t.authConfig.RefreshTokenTTL, requestClient(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrTokenReused):
+			t.logger.WarnContext(r.Context(), "refresh token reuse detected, token family revoked")
+			utils.WriteError(w, r, http.StatusUnauthorized, utils.CodeInvalidToken, "invalid refresh token")
+		case errors.Is(err, store.ErrInvalidToken):
+			utils.WriteError(w, r, http.StatusUnauthorized, utils.CodeInvalidToken, "invalid refresh token")
+		default:
+			serverError(w, r, t.logger, "rotating refresh token failed", err)
+		}
 		return
 	}
 
-	token, err := t.tokenStore.CreateNewToken(user.ID, 24*time.Hour, tokens.ScopeAuth)
+	utils.WriterJSON(w, http.StatusCreated, utils.Envelope{"auth_token": pair.Access, "refresh_token": pair.Refresh})
+}
+
+func (t *TokensHandler) HandleDeleteToken(w http.ResponseWriter, r *http.Request) {
+	err := t.tokenStore.DeleteToken(middleware.GetToken(r))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			utils.WriteError(w, r, http.StatusUnauthorized, utils.CodeInvalidToken, "invalid token")
+			return
+		}
+		serverError(w, r, t.logger, "deleting token failed", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (t *TokensHandler) HandleDeleteAllTokens(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	for _, scope := range []string{tokens.ScopeAuth, tokens.ScopeRefresh} {
+		err := t.tokenStore.DeleteAllTokensForUser(currentUser.ID, scope)
+		if err != nil {
+			serverError(w, r, t.logger, "deleting "+scope+" tokens failed", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (t *TokensHandler) HandleListTokens(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	sessions, err := t.tokenStore.ListSessions(currentUser.ID, middleware.GetToken(r))
 	if err != nil {
-		t.logger.Fatalf("HandleCreateToken cannot create token %v", err)
-		utils.WriterJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		serverError(w, r, t.logger, "listing sessions failed", err)
 		return
 	}
 
-	utils.WriterJSON(w, http.StatusCreated, utils.Envelope{"auth_token": token})
+	utils.WriterJSON(w, http.StatusOK, utils.Envelope{"sessions": sessions})
+}
+
+// invalidCredentials deliberately doesn't tell an unknown email apart from a
+// wrong password.
+func invalidCredentials(w http.ResponseWriter, r *http.Request) {
+	utils.WriteError(w, r, http.StatusUnauthorized, utils.CodeInvalidCredentials, "invalid email or password")
+}
+
+func requestClient(r *http.Request) tokens.Client {
+	return tokens.Client{
+		UserAgent: r.UserAgent(),
+		IPAddress: utils.ClientIP(r),
+	}
 }
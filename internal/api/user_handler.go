@@ -1,18 +1,30 @@
 package api
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
+	"go-server/internal/config"
+	"go-server/internal/mailer"
 	"go-server/internal/store"
+	"go-server/internal/tokens"
 	"go-server/internal/utils"
-	"log"
+	"go-server/internal/validator"
+	"go-server/internal/worker"
+	"go-server/middleware"
+	"log/slog"
 	"net/http"
-	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type UserHandler struct {
-	userStore store.UserStore
-	logger    *log.Logger
+	userStore  store.UserStore
+	tokenStore store.TokenStore
+	mailer     mailer.Mailer
+	workers    *worker.Group
+	authConfig config.AuthConfig
+	logger     *slog.Logger
 }
 
 type registerUserRequest struct {
@@ -22,54 +34,79 @@ type registerUserRequest struct {
 	Bio      string `json:"bio"`
 }
 
-func NewUserHandler(userStore store.UserStore, logger *log.Logger) *UserHandler {
-	return &UserHandler{
-		userStore: userStore,
-		logger:    logger,
-	}
+type activateUserRequest struct {
+	Token string `json:"token"`
 }
 
-func (uh *UserHandler) validateRegisterRequest(registerRequest *registerUserRequest) error {
-	if registerRequest.Username == "" {
-		return errors.New("username is required")
-	}
+type updateUserRequest struct {
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+	Bio      *string `json:"bio"`
+}
 
-	if len(registerRequest.Email) > 50 {
-		return errors.New("email cannot exceed 50 characters")
-	}
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
 
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	if !emailRegex.MatchString(registerRequest.Email) {
-		return errors.New("invalid email format")
-	}
+type publicProfile struct {
+	Username  string    `json:"username"`
+	Bio       string    `json:"bio"`
+	CreatedAt time.Time `json:"created_at"`
+}
 
-	if registerRequest.Bio == "" {
-		return errors.New("bio is required")
+func NewUserHandler(userStore store.UserStore, tokenStore store.TokenStore, mailer mailer.Mailer, workers *worker.Group, authConfig config.AuthConfig, logger *slog.Logger) *UserHandler {
+	return &UserHandler{
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		mailer:     mailer,
+		workers:    workers,
+		authConfig: authConfig,
+		logger:     logger,
 	}
+}
 
-	if registerRequest.Email == "" {
-		return errors.New("email is required")
-	}
+func (uh *UserHandler) validateRegisterRequest(v *validator.Validator, registerRequest *registerUserRequest) {
+	store.ValidateUsername(v, registerRequest.Username)
+	store.ValidateEmail(v, registerRequest.Email)
+	store.ValidatePasswordPlaintext(v, "password", registerRequest.Password)
+	v.Check(validator.NotBlank(registerRequest.Bio), "bio", "is required")
+	v.Check(validator.MaxChars(registerRequest.Bio, 1000), "bio", "cannot exceed 1000 characters")
+}
 
-	if registerRequest.Password == "" {
-		return errors.New("passwordHash is required")
+// writeUserError reports a taken username or email against the offending
+// field and falls back to the generic store error mapping.
+func (uh *UserHandler) writeUserError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	var field string
+	switch {
+	case errors.Is(err, store.ErrDuplicateUsername):
+		field = "username"
+	case errors.Is(err, store.ErrDuplicateEmail):
+		field = "email"
+	default:
+		writeStoreError(w, r, uh.logger, msg, err)
+		return
 	}
-	return nil
+
+	utils.WriteProblem(w, r, utils.Problem{
+		Status: http.StatusConflict,
+		Code:   utils.CodeConflict,
+		Detail: err.Error(),
+		Errors: map[string]string{field: "is already taken"},
+	})
 }
 
 func (uh *UserHandler) HandleCreateUser(resWriter http.ResponseWriter, request *http.Request) {
 	var requestUser = registerUserRequest{}
-	err := json.NewDecoder(request.Body).Decode(&requestUser)
+	err := utils.ReadJSON(resWriter, request, &requestUser)
 	if err != nil {
-		uh.logger.Fatalf("Error while reading user body %v", err)
-		utils.WriterJSON(resWriter, http.StatusBadRequest, utils.Envelope{"error": "Error with request body"})
+		utils.MalformedBody(resWriter, request, err)
 		return
 	}
 
-	err = uh.validateRegisterRequest(&requestUser)
-	if err != nil {
-		uh.logger.Fatalf("Error validation of requestPayload failed %v", err)
-		utils.WriterJSON(resWriter, http.StatusBadRequest, utils.Envelope{"error": "Error with request body validation payload failed"})
+	v := validator.New()
+	if uh.validateRegisterRequest(v, &requestUser); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
 		return
 	}
 
@@ -81,17 +118,267 @@ func (uh *UserHandler) HandleCreateUser(resWriter http.ResponseWriter, request *
 
 	err = user.PasswordHash.Set(requestUser.Password)
 	if err != nil {
-		uh.logger.Fatalf("Error failed to hash password %v", err)
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "Internal error"})
+		serverError(resWriter, request, uh.logger, "hashing password failed", err)
 		return
 	}
 
 	err = uh.userStore.CreateUser(user)
 	if err != nil {
-		uh.logger.Fatalf("Error saving user to database %v", err)
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "Internal error"})
+		uh.writeUserError(resWriter, request, "CreateUser failed", err)
+		return
+	}
+
+	err = uh.sendActivationEmail(user)
+	if err != nil {
+		serverError(resWriter, request, uh.logger, "creating activation token failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"result": user})
+}
+
+func (uh *UserHandler) sendActivationEmail(user *store.User) error {
+	token, err := uh.tokenStore.CreateNewToken(user.ID, uh.authConfig.ActivationTokenTTL, tokens.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	sendMailInBackground(uh.workers, uh.mailer, uh.logger, mailer.Message{
+		To:      user.Email,
+		Subject: "Activate your account",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSend the following token to PUT /users/activated to activate your account:\n\n%s\n\nThe token expires at %s.\n",
+			user.Username, token.PlainText, token.Expiry.Format(time.RFC1123),
+		),
+	})
+
+	return nil
+}
+
+func (uh *UserHandler) HandleActivateUser(resWriter http.ResponseWriter, request *http.Request) {
+	var req activateUserRequest
+	err := utils.ReadJSON(resWriter, request, &req)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+	v := validator.New()
+	if v.Check(req.Token != "", "token", "is required"); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	user, err := uh.userStore.GetUserToken(tokens.ScopeActivation, req.Token)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.FailedValidation(resWriter, request, map[string]string{"token": "invalid or expired activation token"})
+		return
+	}
+	if err != nil {
+		serverError(resWriter, request, uh.logger, "looking up activation token failed", err)
+		return
+	}
+
+	err = uh.userStore.ActivateUser(user)
+	if err != nil {
+		writeStoreError(resWriter, request, uh.logger, "activating user failed", err)
+		return
+	}
+
+	err = uh.tokenStore.DeleteAllTokensForUser(user.ID, tokens.ScopeActivation)
+	if err != nil {
+		serverError(resWriter, request, uh.logger, "deleting activation tokens failed", err)
 		return
 	}
 
 	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"result": user})
 }
+
+func (uh *UserHandler) HandleGetCurrentUser(resWriter http.ResponseWriter, request *http.Request) {
+	currentUser := middleware.GetUser(request)
+
+	user, err := uh.userStore.GetUserByID(currentUser.ID)
+	if err != nil {
+		writeStoreError(resWriter, request, uh.logger, "loading user failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"user": user})
+}
+
+func (uh *UserHandler) HandleUpdateCurrentUser(resWriter http.ResponseWriter, request *http.Request) {
+	var req updateUserRequest
+	err := utils.ReadJSON(resWriter, request, &req)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	v := validator.New()
+	if req.Username != nil {
+		store.ValidateUsername(v, *req.Username)
+	}
+	if req.Email != nil {
+		store.ValidateEmail(v, *req.Email)
+	}
+	if req.Bio != nil {
+		v.Check(validator.MaxChars(*req.Bio, 1000), "bio", "cannot exceed 1000 characters")
+	}
+	if !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	currentUser := middleware.GetUser(request)
+	user, err := uh.userStore.GetUserByID(currentUser.ID)
+	if err != nil {
+		writeStoreError(resWriter, request, uh.logger, "loading user failed", err)
+		return
+	}
+
+	emailChanged := false
+	if req.Username != nil {
+		user.Username = *req.Username
+	}
+
+	if req.Email != nil {
+		emailChanged = *req.Email != user.Email
+		user.Email = *req.Email
+	}
+
+	if req.Bio != nil {
+		user.Bio = *req.Bio
+	}
+
+	// a new address has to be verified again before workout writes are allowed
+	if emailChanged {
+		user.Activated = false
+	}
+
+	err = uh.userStore.UpdateUser(user)
+	if err != nil {
+		uh.writeUserError(resWriter, request, "updating user failed", err)
+		return
+	}
+
+	// the update is already saved, so a failure here is left to
+	// POST /users/me/activation-token rather than reported as an error
+	if emailChanged {
+		err = uh.sendActivationEmail(user)
+		if err != nil {
+			uh.logger.ErrorContext(request.Context(), "creating activation token failed", "error", err)
+		}
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"user": user})
+}
+
+// HandleResendActivation mails a new activation token to the current address
+// and revokes the ones sent before.
+func (uh *UserHandler) HandleResendActivation(resWriter http.ResponseWriter, request *http.Request) {
+	user, err := uh.userStore.GetUserByID(middleware.GetUser(request).ID)
+	if err != nil {
+		writeStoreError(resWriter, request, uh.logger, "loading user failed", err)
+		return
+	}
+
+	if user.Activated {
+		utils.WriteError(resWriter, request, http.StatusConflict, utils.CodeConflict, "the account is already activated")
+		return
+	}
+
+	err = uh.tokenStore.DeleteAllTokensForUser(user.ID, tokens.ScopeActivation)
+	if err != nil {
+		serverError(resWriter, request, uh.logger, "revoking activation tokens failed", err)
+		return
+	}
+
+	err = uh.sendActivationEmail(user)
+	if err != nil {
+		serverError(resWriter, request, uh.logger, "creating activation token failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusAccepted, utils.Envelope{"message": "an activation token has been sent to " + user.Email})
+}
+
+func (uh *UserHandler) HandleChangePassword(resWriter http.ResponseWriter, request *http.Request) {
+	var req changePasswordRequest
+	err := utils.ReadJSON(resWriter, request, &req)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(req.CurrentPassword != "", "current_password", "is required")
+	if store.ValidatePasswordPlaintext(v, "new_password", req.NewPassword); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	currentUser := middleware.GetUser(request)
+	user, err := uh.userStore.GetUserByID(currentUser.ID)
+	if err != nil {
+		writeStoreError(resWriter, request, uh.logger, "loading user failed", err)
+		return
+	}
+
+	matches, err := user.PasswordHash.Matches(req.CurrentPassword)
+	if err != nil {
+		serverError(resWriter, request, uh.logger, "matching password failed", err)
+		return
+	}
+
+	if !matches {
+		utils.WriteProblem(resWriter, request, utils.Problem{
+			Status: http.StatusForbidden,
+			Code:   utils.CodeInvalidCredentials,
+			Detail: "current password is incorrect",
+			Errors: map[string]string{"current_password": "is incorrect"},
+		})
+		return
+	}
+
+	err = user.PasswordHash.Set(req.NewPassword)
+	if err != nil {
+		serverError(resWriter, request, uh.logger, "hashing password failed", err)
+		return
+	}
+
+	// other sessions may be the reason for the change, so only this one stays
+	err = uh.userStore.UpdatePassword(user, middleware.GetToken(request))
+	if err != nil {
+		writeStoreError(resWriter, request, uh.logger, "updating password failed", err)
+		return
+	}
+
+	resWriter.WriteHeader(http.StatusNoContent)
+}
+
+func (uh *UserHandler) HandleDeleteCurrentUser(resWriter http.ResponseWriter, request *http.Request) {
+	currentUser := middleware.GetUser(request)
+
+	err := uh.userStore.DeleteUser(currentUser.ID)
+	if err != nil {
+		writeStoreError(resWriter, request, uh.logger, "deleting user failed", err)
+		return
+	}
+
+	resWriter.WriteHeader(http.StatusNoContent)
+}
+
+func (uh *UserHandler) HandleGetUserByUsername(resWriter http.ResponseWriter, request *http.Request) {
+	username := chi.URLParam(request, "username")
+
+	user, err := uh.userStore.GetUserByUsername(username)
+	if err != nil {
+		writeStoreError(resWriter, request, uh.logger, "loading user failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"user": publicProfile{
+		Username:  user.Username,
+		Bio:       user.Bio,
+		CreatedAt: user.CreatedAt,
+	}})
+}
@@ -1,40 +1,110 @@
 package api
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
+	"go-server/internal/metrics"
 	"go-server/internal/store"
 	"go-server/internal/utils"
+	"go-server/internal/validator"
 	"go-server/middleware"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
 type WorkoutHandler struct {
 	workoutStore store.WorkoutStore
-	logger       *log.Logger
+	followStore  store.FollowStore
+	metrics      *metrics.Metrics
+	logger       *slog.Logger
 }
 
-func NewWorkoutHandler(workoutStore store.WorkoutStore, logger *log.Logger) *WorkoutHandler {
+func NewWorkoutHandler(workoutStore store.WorkoutStore, followStore store.FollowStore, metrics *metrics.Metrics, logger *slog.Logger) *WorkoutHandler {
 	return &WorkoutHandler{
 		workoutStore: workoutStore,
+		followStore:  followStore,
+		metrics:      metrics,
 		logger:       logger,
 	}
 }
 
+// canView checks the workout's visibility against the requesting user.
+// Follow state is only looked up when it decides the outcome.
+func (wh *WorkoutHandler) canView(viewer *store.User, workout *store.Workout) (bool, error) {
+	viewerID := 0
+	if !viewer.IsAnonymous() {
+		viewerID = viewer.ID
+	}
+
+	following := false
+	if workout.Visibility == store.VisibilityFollowers && viewerID != 0 && viewerID != workout.UserID {
+		var err error
+		following, err = wh.followStore.IsFollowing(viewerID, workout.UserID)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return workout.VisibleTo(viewerID, following), nil
+}
+
+// requireOwner writes a problem response and returns false unless the
+// current user owns the workout.
+func (wh *WorkoutHandler) requireOwner(resWriter http.ResponseWriter, request *http.Request, workoutID int64) bool {
+	workoutOwner, err := wh.workoutStore.GetWorkoutOwner(workoutID)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "GetWorkoutOwner failed", err)
+		return false
+	}
+
+	if workoutOwner != middleware.GetUser(request).ID {
+		utils.Forbidden(resWriter, request, "you can only change your own workouts")
+		return false
+	}
+
+	return true
+}
+
+// ifMatch returns the workout version from If-Match. Writes require it so
+// that concurrent edits fail with 412 instead of overwriting each other.
+func (wh *WorkoutHandler) ifMatch(resWriter http.ResponseWriter, request *http.Request) (int, bool) {
+	version, ok := utils.IfMatchVersion(request)
+	if !ok {
+		utils.WriteError(resWriter, request, http.StatusPreconditionRequired, utils.CodePreconditionRequired, "this request must include an If-Match header with the workout's ETag")
+	}
+
+	return version, ok
+}
+
 func (wh *WorkoutHandler) HandleGetWorkoutById(resWriter http.ResponseWriter, request *http.Request) {
 	workoutId, err := utils.ReadID(request)
 	if err != nil {
-		wh.logger.Printf("Error: while reading param %v", err)
-		utils.WriterJSON(resWriter, http.StatusNotFound, utils.Envelope{"error": "invalid workout id"})
+		utils.InvalidID(resWriter, request)
 		return
 	}
 
 	workout, err := wh.workoutStore.GetWorkoutByID(workoutId)
 	if err != nil {
-		wh.logger.Printf("Error: while executing GetWorkoutByID %v", err)
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		writeStoreError(resWriter, request, wh.logger, "GetWorkoutByID failed", err)
+		return
+	}
+
+	visible, err := wh.canView(middleware.GetUser(request), workout)
+	if err != nil {
+		serverError(resWriter, request, wh.logger, "IsFollowing failed", err)
+		return
+	}
+
+	// hidden workouts look exactly like missing ones so ids cannot be probed
+	if !visible {
+		utils.NotFound(resWriter, request, "the requested resource could not be found")
+		return
+	}
+
+	etag := utils.ETag(workout.Version)
+	resWriter.Header().Set("ETag", etag)
+	if utils.NotModified(request, etag) {
+		resWriter.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -43,108 +113,461 @@ func (wh *WorkoutHandler) HandleGetWorkoutById(resWriter http.ResponseWriter, re
 
 func (wh *WorkoutHandler) HandleCreateWorkout(resWriter http.ResponseWriter, request *http.Request) {
 	var workout store.Workout
-	err := json.NewDecoder(request.Body).Decode(&workout)
+	err := utils.ReadJSON(resWriter, request, &workout)
 	if err != nil {
-		wh.logger.Printf("Error: while decoding request body %v", err)
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		utils.MalformedBody(resWriter, request, err)
 		return
 	}
 
-	currentUser := middleware.GetUser(request)
-	if currentUser == nil || currentUser == store.AnonymousUser {
-		utils.WriterJSON(resWriter, http.StatusBadRequest, utils.Envelope{"error": "cannot find user related to workout"})
+	if workout.Visibility == "" {
+		workout.Visibility = store.VisibilityPrivate
+	}
+
+	v := validator.New()
+	if store.ValidateWorkout(v, &workout); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
 		return
 	}
 
+	currentUser := middleware.GetUser(request)
 	workout.UserID = currentUser.ID
 
 	createdWorkout, err := wh.workoutStore.CreateWorkout(&workout)
 	if err != nil {
-		wh.logger.Printf("Error: while executing CreateWorkout %v", err)
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		writeStoreError(resWriter, request, wh.logger, "CreateWorkout failed", err)
 		return
 	}
+	wh.metrics.WorkoutCreated()
 
+	resWriter.Header().Set("ETag", utils.ETag(createdWorkout.Version))
 	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"workout": createdWorkout})
 }
 
 func (wh *WorkoutHandler) HandleUpdateWorkout(resWriter http.ResponseWriter, request *http.Request) {
+	version, ok := wh.ifMatch(resWriter, request)
+	if !ok {
+		return
+	}
+
 	var workout store.Workout
-	err := json.NewDecoder(request.Body).Decode(&workout)
+	err := utils.ReadJSON(resWriter, request, &workout)
 	if err != nil {
-		wh.logger.Printf("Error: while decoding request body %v", err)
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		utils.MalformedBody(resWriter, request, err)
 		return
 	}
 
-	currentUser := middleware.GetUser(request)
-	if currentUser == nil || currentUser == store.AnonymousUser {
-		utils.WriterJSON(resWriter, http.StatusBadRequest, utils.Envelope{"error": "cannot find user related to workout"})
+	v := validator.New()
+	if v.Check(workout.ID > 0, "id", "is required"); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
 		return
 	}
 
-	workoutOwner, err := wh.workoutStore.GetWorkoutOwner(int64(workout.ID))
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			utils.WriterJSON(resWriter, http.StatusNotFound, utils.Envelope{"error": "workout not exist"})
+	if !wh.requireOwner(resWriter, request, int64(workout.ID)) {
+		return
+	}
+
+	// an omitted visibility keeps the stored one instead of making the
+	// workout private, a stale read fails the version check below
+	if workout.Visibility == "" {
+		existing, err := wh.workoutStore.GetWorkoutByID(int64(workout.ID))
+		if err != nil {
+			writeStoreError(resWriter, request, wh.logger, "GetWorkoutByID failed", err)
 			return
 		}
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		workout.Visibility = existing.Visibility
+	}
+
+	if store.ValidateWorkout(v, &workout); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
 		return
 	}
 
-	if workoutOwner != currentUser.ID {
-		utils.WriterJSON(resWriter, http.StatusForbidden, utils.Envelope{"error": "not authorized to perform this action"})
+	workout.Version = version
+	err = wh.workoutStore.UpdateWorkout(&workout)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "UpdateWorkout failed", err)
 		return
 	}
 
-	workout.UserID = currentUser.ID
+	resWriter.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"workout": workout})
+}
+
+// HandlePatchWorkout applies a JSON merge patch (RFC 7396) to a workout.
+// An "entries" member replaces the entry list; entries that keep their id
+// are updated in place.
+func (wh *WorkoutHandler) HandlePatchWorkout(resWriter http.ResponseWriter, request *http.Request) {
+	workoutId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	version, ok := wh.ifMatch(resWriter, request)
+	if !ok {
+		return
+	}
+
+	var patch json.RawMessage
+	err = utils.ReadJSON(resWriter, request, &patch)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	if !wh.requireOwner(resWriter, request, workoutId) {
+		return
+	}
+
+	existing, err := wh.workoutStore.GetWorkoutByID(workoutId)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "GetWorkoutByID failed", err)
+		return
+	}
+
+	var workout store.Workout
+	err = utils.ApplyMergePatch(existing, patch, &workout)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+	workout.ID = existing.ID
+	workout.UserID = existing.UserID
+	workout.CreatedAt = existing.CreatedAt
+	workout.Version = version
+
+	v := validator.New()
+	if store.ValidateWorkout(v, &workout); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
 
 	err = wh.workoutStore.UpdateWorkout(&workout)
 	if err != nil {
-		wh.logger.Printf("Error: while executing UpdateWorkout %v", err)
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		writeStoreError(resWriter, request, wh.logger, "UpdateWorkout failed", err)
 		return
 	}
 
+	resWriter.Header().Set("ETag", utils.ETag(workout.Version))
 	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"workout": workout})
 }
 
 func (wh *WorkoutHandler) HandleDelete(resWriter http.ResponseWriter, request *http.Request) {
 	workoutId, err := utils.ReadID(request)
 	if err != nil {
-		wh.logger.Printf("Error: while reading param %v", err)
-		utils.WriterJSON(resWriter, http.StatusNotFound, utils.Envelope{"error": "invalid workout id"})
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	version, ok := wh.ifMatch(resWriter, request)
+	if !ok {
+		return
+	}
+
+	if !wh.requireOwner(resWriter, request, workoutId) {
+		return
+	}
+
+	err = wh.workoutStore.DeleteWorkout(workoutId, version)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "DeleteWorkout failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"removedElement": workoutId})
+}
+
+func (wh *WorkoutHandler) HandleListWorkouts(resWriter http.ResponseWriter, request *http.Request) {
+	wh.listWorkouts(resWriter, request, false)
+}
+
+// HandleListTrash lists the current user's deleted workouts that have not
+// been purged yet. It takes the same query parameters as HandleListWorkouts.
+func (wh *WorkoutHandler) HandleListTrash(resWriter http.ResponseWriter, request *http.Request) {
+	wh.listWorkouts(resWriter, request, true)
+}
+
+func (wh *WorkoutHandler) HandleRestoreWorkout(resWriter http.ResponseWriter, request *http.Request) {
+	workoutId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	err = wh.workoutStore.RestoreWorkout(workoutId, middleware.GetUser(request).ID)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "RestoreWorkout failed", err)
+		return
+	}
+
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutId)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "GetWorkoutByID failed", err)
 		return
 	}
 
+	resWriter.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"workout": workout})
+}
+
+func (wh *WorkoutHandler) listWorkouts(resWriter http.ResponseWriter, request *http.Request, trashed bool) {
 	currentUser := middleware.GetUser(request)
-	if currentUser == nil || currentUser == store.AnonymousUser {
-		utils.WriterJSON(resWriter, http.StatusBadRequest, utils.Envelope{"error": "cannot find user related to workout"})
+	query := request.URL.Query()
+
+	filter := store.WorkoutFilter{
+		UserID:  currentUser.ID,
+		Trashed: trashed,
+		Title:   query.Get("title"),
+		Sort:    query.Get("sort"),
+		Cursor:  query.Get("cursor"),
+	}
+
+	if filter.Sort != "" && !store.ValidWorkoutSort(filter.Sort) {
+		utils.BadRequest(resWriter, request, "invalid sort parameter")
+		return
+	}
+
+	var err error
+	intParams := map[string]**int{
+		"min_duration": &filter.MinDuration,
+		"max_duration": &filter.MaxDuration,
+		"min_calories": &filter.MinCalories,
+		"max_calories": &filter.MaxCalories,
+	}
+	for key, target := range intParams {
+		*target, err = utils.ReadQueryInt(request, key)
+		if err != nil {
+			utils.BadRequest(resWriter, request, err.Error())
+			return
+		}
+	}
+
+	limit, err := utils.ReadQueryInt(request, "limit")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+	if limit != nil {
+		filter.Limit = *limit
+	}
+
+	filter.From, err = utils.ReadQueryTime(request, "from")
+	if err != nil {
+		utils.BadRequest(resWriter, request, err.Error())
 		return
 	}
 
-	workoutOwner, err := wh.workoutStore.GetWorkoutOwner(workoutId)
+	filter.To, err = utils.ReadQueryTime(request, "to")
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			utils.WriterJSON(resWriter, http.StatusNotFound, utils.Envelope{"error": "workout not exist"})
+		utils.BadRequest(resWriter, request, err.Error())
+		return
+	}
+
+	page, err := wh.workoutStore.ListWorkouts(filter)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			utils.BadRequest(resWriter, request, "invalid cursor, it must come from a page with the same sort")
 			return
 		}
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		writeStoreError(resWriter, request, wh.logger, "ListWorkouts failed", err)
+		return
+	}
+
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{
+		"workouts": page.Workouts,
+		"metadata": utils.Envelope{
+			"next_cursor": page.NextCursor,
+			"total_count": page.TotalCount,
+		},
+	})
+}
+
+func (wh *WorkoutHandler) HandleCreateEntry(resWriter http.ResponseWriter, request *http.Request) {
+	workoutId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
 		return
 	}
 
-	if workoutOwner != currentUser.ID {
-		utils.WriterJSON(resWriter, http.StatusForbidden, utils.Envelope{"error": "not authorized to perform this action"})
+	version, ok := wh.ifMatch(resWriter, request)
+	if !ok {
 		return
 	}
 
-	err = wh.workoutStore.DeleteWorkout(workoutId)
+	var entry store.WorkoutEntry
+	err = utils.ReadJSON(resWriter, request, &entry)
 	if err != nil {
-		wh.logger.Printf("Error: while executing DeleteWorkout %v", err)
-		utils.WriterJSON(resWriter, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		utils.MalformedBody(resWriter, request, err)
 		return
 	}
+	entry.ID = 0
 
-	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"removedElement": workoutId})
+	v := validator.New()
+	if store.ValidateWorkoutEntry(v, "", &entry); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	if !wh.requireOwner(resWriter, request, workoutId) {
+		return
+	}
+
+	version, err = wh.workoutStore.CreateWorkoutEntry(workoutId, version, &entry)
+	if err != nil {
+		if errors.Is(err, store.ErrTooManyEntries) {
+			utils.FailedValidation(resWriter, request, map[string]string{"entries": err.Error()})
+			return
+		}
+		writeStoreError(resWriter, request, wh.logger, "CreateWorkoutEntry failed", err)
+		return
+	}
+
+	resWriter.Header().Set("ETag", utils.ETag(version))
+	utils.WriterJSON(resWriter, http.StatusCreated, utils.Envelope{"entry": entry})
+}
+
+// HandlePatchEntry applies a JSON merge patch (RFC 7396) to a single entry.
+func (wh *WorkoutHandler) HandlePatchEntry(resWriter http.ResponseWriter, request *http.Request) {
+	workoutId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	entryId, err := utils.ReadIDParam(request, "entryId")
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	version, ok := wh.ifMatch(resWriter, request)
+	if !ok {
+		return
+	}
+
+	var patch json.RawMessage
+	err = utils.ReadJSON(resWriter, request, &patch)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	if !wh.requireOwner(resWriter, request, workoutId) {
+		return
+	}
+
+	existing, err := wh.workoutStore.GetWorkoutEntry(workoutId, entryId)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "GetWorkoutEntry failed", err)
+		return
+	}
+
+	var entry store.WorkoutEntry
+	err = utils.ApplyMergePatch(existing, patch, &entry)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+	entry.ID = existing.ID
+
+	v := validator.New()
+	if store.ValidateWorkoutEntry(v, "", &entry); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	version, err = wh.workoutStore.UpdateWorkoutEntry(workoutId, version, &entry)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "UpdateWorkoutEntry failed", err)
+		return
+	}
+
+	resWriter.Header().Set("ETag", utils.ETag(version))
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"entry": entry})
+}
+
+func (wh *WorkoutHandler) HandleDeleteEntry(resWriter http.ResponseWriter, request *http.Request) {
+	workoutId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	entryId, err := utils.ReadIDParam(request, "entryId")
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	version, ok := wh.ifMatch(resWriter, request)
+	if !ok {
+		return
+	}
+
+	if !wh.requireOwner(resWriter, request, workoutId) {
+		return
+	}
+
+	version, err = wh.workoutStore.DeleteWorkoutEntry(workoutId, version, entryId)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "DeleteWorkoutEntry failed", err)
+		return
+	}
+
+	resWriter.Header().Set("ETag", utils.ETag(version))
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"removedElement": entryId})
+}
+
+type reorderEntriesRequest struct {
+	EntryIDs []int `json:"entry_ids"`
+}
+
+func (wh *WorkoutHandler) HandleReorderEntries(resWriter http.ResponseWriter, request *http.Request) {
+	workoutId, err := utils.ReadID(request)
+	if err != nil {
+		utils.InvalidID(resWriter, request)
+		return
+	}
+
+	version, ok := wh.ifMatch(resWriter, request)
+	if !ok {
+		return
+	}
+
+	var req reorderEntriesRequest
+	err = utils.ReadJSON(resWriter, request, &req)
+	if err != nil {
+		utils.MalformedBody(resWriter, request, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(req.EntryIDs != nil, "entry_ids", "is required")
+	if v.Check(validator.Unique(req.EntryIDs), "entry_ids", "cannot contain duplicates"); !v.Valid() {
+		utils.FailedValidation(resWriter, request, v.Errors)
+		return
+	}
+
+	if !wh.requireOwner(resWriter, request, workoutId) {
+		return
+	}
+
+	_, err = wh.workoutStore.ReorderWorkoutEntries(workoutId, version, req.EntryIDs)
+	if err != nil {
+		if errors.Is(err, store.ErrUnknownEntry) {
+			utils.FailedValidation(resWriter, request, map[string]string{"entry_ids": "must list every entry of the workout exactly once"})
+			return
+		}
+		writeStoreError(resWriter, request, wh.logger, "ReorderWorkoutEntries failed", err)
+		return
+	}
+
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutId)
+	if err != nil {
+		writeStoreError(resWriter, request, wh.logger, "GetWorkoutByID failed", err)
+		return
+	}
+
+	resWriter.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriterJSON(resWriter, http.StatusOK, utils.Envelope{"workout": workout})
 }
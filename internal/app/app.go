@@ -1,28 +1,52 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"go-server/internal/api"
+	"go-server/internal/config"
+	"go-server/internal/health"
+	"go-server/internal/logging"
+	"go-server/internal/mailer"
+	"go-server/internal/metrics"
 	"go-server/internal/store"
+	"go-server/internal/trainingload"
+	"go-server/internal/worker"
 	"go-server/middleware"
 	"go-server/migrations"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"time"
 )
 
+const readinessTimeout = 2 * time.Second
+
 type Application struct {
-	Logger         *log.Logger
-	WorkoutHandler *api.WorkoutHandler
-	UserHandler    *api.UserHandler
-	TokenHandler   *api.TokensHandler
-	UserMiddleware middleware.UserMiddleware
-	DB             *sql.DB
+	Config          *config.Config
+	Logger          *slog.Logger
+	Metrics         *metrics.Metrics
+	Health          *health.Checker
+	WorkoutHandler  *api.WorkoutHandler
+	ExerciseHandler *api.ExerciseHandler
+	RecordHandler   *api.RecordHandler
+	PasswordHandler *api.PasswordResetHandler
+	UserHandler     *api.UserHandler
+	TokenHandler    *api.TokensHandler
+	FollowHandler   *api.FollowHandler
+	TemplateHandler *api.TemplateHandler
+	ProgramHandler  *api.ProgramHandler
+	StatsHandler    *api.StatsHandler
+	UserMiddleware  middleware.UserMiddleware
+	Workers         *worker.Group
+	DB              *sql.DB
 }
 
-func NewApplication() (*Application, error) {
-	pgDB, err := store.Open()
+func NewApplication(cfg *config.Config) (*Application, error) {
+	store.SetBcryptCost(cfg.Auth.BcryptCost)
+
+	pgDB, err := store.Open(cfg.Database)
 	if err != nil {
 		return nil, err
 	}
@@ -32,29 +56,107 @@ func NewApplication() (*Application, error) {
 		panic(err)
 	}
 
-	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	logger := logging.New(os.Stdout, cfg.LogLevel)
+	mailer := newMailer(cfg.Mail)
+	workers := worker.NewGroup(logger)
+	appMetrics := metrics.New(pgDB)
+
+	checker := health.NewChecker(readinessTimeout, logger)
+	checker.Register("database", pgDB.PingContext)
+	checker.Register("migrations", migrationsCheck(pgDB))
 
 	workoutStore := store.NewPostgresWorkoutStore(pgDB)
 	userStore := store.NewPostgresUserStore(pgDB)
 	tokenStore := store.NewPostgresTokenStore(pgDB)
-	userMiddleware := middleware.UserMiddleware{UserStore: userStore}
+	exerciseStore := store.NewPostgresExerciseStore(pgDB)
+	recordStore := store.NewPostgresRecordStore(pgDB)
+	followStore := store.NewPostgresFollowStore(pgDB)
+	templateStore := store.NewPostgresTemplateStore(pgDB)
+	programStore := store.NewPostgresProgramStore(pgDB)
+	statsStore := store.NewPostgresStatsStore(pgDB)
+	workers.Every("purge trash", cfg.Trash.PurgeInterval, purgeTrash(workoutStore, cfg.Trash.Retention, logger))
 
-	workoutHandler := api.NewWorkoutHandler(workoutStore, logger)
-	userHandler := api.NewUserHandler(userStore, logger)
-	tokenHandler := api.NewTokenHandler(tokenStore, userStore, logger)
+	userMiddleware := middleware.UserMiddleware{UserStore: userStore, TokenStore: tokenStore, Metrics: appMetrics, Logger: logger}
+
+	workoutHandler := api.NewWorkoutHandler(workoutStore, followStore, appMetrics, logger)
+	exerciseHandler := api.NewExerciseHandler(exerciseStore, logger)
+	recordHandler := api.NewRecordHandler(recordStore, logger)
+	passwordHandler := api.NewPasswordResetHandler(userStore, tokenStore, mailer, workers, cfg.Auth, logger)
+	userHandler := api.NewUserHandler(userStore, tokenStore, mailer, workers, cfg.Auth, logger)
+	tokenHandler := api.NewTokenHandler(tokenStore, userStore, cfg.Auth, logger)
+	followHandler := api.NewFollowHandler(followStore, userStore, logger)
+	templateHandler := api.NewTemplateHandler(templateStore, workoutStore, appMetrics, logger)
+	programHandler := api.NewProgramHandler(programStore, templateStore, workoutStore, appMetrics, logger)
+	statsHandler := api.NewStatsHandler(statsStore, trainingload.Thresholds{Low: cfg.Load.ACWRLow, High: cfg.Load.ACWRHigh}, logger)
 
 	app := &Application{
-		DB:             pgDB,
-		Logger:         logger,
-		WorkoutHandler: workoutHandler,
-		UserHandler:    userHandler,
-		TokenHandler:   tokenHandler,
-		UserMiddleware: userMiddleware,
+		Config:          cfg,
+		DB:              pgDB,
+		Logger:          logger,
+		Metrics:         appMetrics,
+		Health:          checker,
+		WorkoutHandler:  workoutHandler,
+		ExerciseHandler: exerciseHandler,
+		RecordHandler:   recordHandler,
+		PasswordHandler: passwordHandler,
+		UserHandler:     userHandler,
+		TokenHandler:    tokenHandler,
+		FollowHandler:   followHandler,
+		TemplateHandler: templateHandler,
+		ProgramHandler:  programHandler,
+		StatsHandler:    statsHandler,
+		UserMiddleware:  userMiddleware,
+		Workers:         workers,
 	}
 
 	return app, nil
 }
 
-func (a *Application) HealthCheck(resWriter http.ResponseWriter, request *http.Request) {
-	fmt.Fprintf(resWriter, "Status is available\n")
+// Close stops background workers before closing the database they may
+// still be using.
+func (a *Application) Close(ctx context.Context) error {
+	err := a.Workers.Shutdown(ctx)
+	if err != nil {
+		a.Logger.Error("background workers did not stop in time", "error", err)
+	}
+
+	return errors.Join(err, a.DB.Close())
+}
+
+func newMailer(cfg config.MailConfig) mailer.Mailer {
+	if cfg.SMTPHost == "" {
+		return mailer.NewWriterMailer(os.Stdout, cfg.Sender)
+	}
+
+	return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.Sender)
+}
+
+// migrationsCheck fails readiness while the schema is behind the migrations
+// embedded in this binary, e.g. during a rolling deploy.
+func migrationsCheck(db *sql.DB) health.CheckFunc {
+	return func(ctx context.Context) error {
+		current, latest, err := store.MigrationVersions(ctx, db, migrations.FC)
+		if err != nil {
+			return err
+		}
+		if current < latest {
+			return fmt.Errorf("schema at version %d, latest is %d", current, latest)
+		}
+		return nil
+	}
+}
+
+// purgeTrash permanently deletes workouts that have been in the trash for
+// longer than retention.
+func purgeTrash(workoutStore store.WorkoutStore, retention time.Duration, logger *slog.Logger) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := workoutStore.PurgeDeletedWorkouts(time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			logger.InfoContext(ctx, "purged deleted workouts", "count", purged)
+		}
+		return nil
+	}
 }
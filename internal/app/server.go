@@ -0,0 +1,41 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Serve runs the server on the listener until ctx is cancelled, then stops
+// accepting connections and waits up to the configured shutdown timeout for
+// in-flight requests to finish.
+func (a *Application) Serve(ctx context.Context, server *http.Server, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	a.Logger.Info("shutdown signal received, draining requests", "timeout", a.Config.Server.ShutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.Config.Server.ShutdownTimeout)
+	defer cancel()
+
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+
+	err = <-serveErr
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
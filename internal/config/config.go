@@ -0,0 +1,303 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Mail     MailConfig     `yaml:"mail"`
+	Trash    TrashConfig    `yaml:"trash"`
+	Load     LoadConfig     `yaml:"load"`
+	LogLevel string         `yaml:"log_level"`
+}
+
+type ServerConfig struct {
+	Port            int           `yaml:"port"`
+	AdminPort       int           `yaml:"admin_port"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+type AuthConfig struct {
+	AccessTokenTTL        time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL       time.Duration `yaml:"refresh_token_ttl"`
+	PasswordResetTokenTTL time.Duration `yaml:"password_reset_token_ttl"`
+	ActivationTokenTTL    time.Duration `yaml:"activation_token_ttl"`
+	BcryptCost            int           `yaml:"bcrypt_cost"`
+}
+
+type MailConfig struct {
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	Sender       string `yaml:"sender"`
+}
+
+// TrashConfig controls how long deleted workouts can be restored before the
+// purge job removes them for good.
+type TrashConfig struct {
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+// LoadConfig sets the acute:chronic workload ratio band outside of which
+// training load is flagged, as too low or as an injury risk.
+type LoadConfig struct {
+	ACWRLow  float64 `yaml:"acwr_low"`
+	ACWRHigh float64 `yaml:"acwr_high"`
+}
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            8080,
+			AdminPort:       9090,
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			DSN:             "host=localhost user=postgres password=postgres dbname=postgres sslmode=disable port=5432",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:        24 * time.Hour,
+			RefreshTokenTTL:       30 * 24 * time.Hour,
+			PasswordResetTokenTTL: 45 * time.Minute,
+			ActivationTokenTTL:    3 * 24 * time.Hour,
+			BcryptCost:            12,
+		},
+		Mail: MailConfig{
+			SMTPPort: 587,
+			Sender:   "Go Gym <no-reply@go-gym.local>",
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Load: LoadConfig{
+			ACWRLow:  0.8,
+			ACWRHigh: 1.5,
+		},
+		LogLevel: "info",
+	}
+}
+
+// setting binds one configuration value to its environment variable and
+// command line flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(cfg *Config, value string) error
+}
+
+func stringSetting(field func(*Config) *string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}
+}
+
+func intSetting(field func(*Config) *int) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = i
+		return nil
+	}
+}
+
+func floatSetting(field func(*Config) *float64) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = f
+		return nil
+	}
+}
+
+func durationSetting(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = d
+		return nil
+	}
+}
+
+var settings = []setting{
+	{env: "GYM_PORT", flag: "port", usage: "go backend server port", set: intSetting(func(c *Config) *int { return &c.Server.Port })},
+	{env: "GYM_ADMIN_PORT", flag: "admin-port", usage: "port serving /metrics, 0 disables it", set: intSetting(func(c *Config) *int { return &c.Server.AdminPort })},
+	{env: "GYM_READ_TIMEOUT", flag: "read-timeout", usage: "HTTP server read timeout", set: durationSetting(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+	{env: "GYM_WRITE_TIMEOUT", flag: "write-timeout", usage: "HTTP server write timeout", set: durationSetting(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+	{env: "GYM_IDLE_TIMEOUT", flag: "idle-timeout", usage: "HTTP server idle timeout", set: durationSetting(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+	{env: "GYM_SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "how long to wait for in-flight requests and background tasks on shutdown", set: durationSetting(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+	{env: "GYM_DB_DSN", flag: "db-dsn", usage: "PostgreSQL DSN", set: stringSetting(func(c *Config) *string { return &c.Database.DSN })},
+	{env: "GYM_DB_MAX_OPEN_CONNS", flag: "db-max-open-conns", usage: "maximum open database connections, 0 means unlimited", set: intSetting(func(c *Config) *int { return &c.Database.MaxOpenConns })},
+	{env: "GYM_DB_MAX_IDLE_CONNS", flag: "db-max-idle-conns", usage: "maximum idle database connections", set: intSetting(func(c *Config) *int { return &c.Database.MaxIdleConns })},
+	{env: "GYM_DB_CONN_MAX_LIFETIME", flag: "db-conn-max-lifetime", usage: "maximum lifetime of a database connection, 0 means unlimited", set: durationSetting(func(c *Config) *time.Duration { return &c.Database.ConnMaxLifetime })},
+	{env: "GYM_DB_CONN_MAX_IDLE_TIME", flag: "db-conn-max-idle-time", usage: "maximum idle time of a database connection, 0 means unlimited", set: durationSetting(func(c *Config) *time.Duration { return &c.Database.ConnMaxIdleTime })},
+	{env: "GYM_ACCESS_TOKEN_TTL", flag: "access-token-ttl", usage: "lifetime of authentication tokens", set: durationSetting(func(c *Config) *time.Duration { return &c.Auth.AccessTokenTTL })},
+	{env: "GYM_REFRESH_TOKEN_TTL", flag: "refresh-token-ttl", usage: "lifetime of refresh tokens", set: durationSetting(func(c *Config) *time.Duration { return &c.Auth.RefreshTokenTTL })},
+	{env: "GYM_PASSWORD_RESET_TOKEN_TTL", flag: "password-reset-token-ttl", usage: "lifetime of password reset tokens", set: durationSetting(func(c *Config) *time.Duration { return &c.Auth.PasswordResetTokenTTL })},
+	{env: "GYM_ACTIVATION_TOKEN_TTL", flag: "activation-token-ttl", usage: "lifetime of account activation tokens", set: durationSetting(func(c *Config) *time.Duration { return &c.Auth.ActivationTokenTTL })},
+	{env: "GYM_BCRYPT_COST", flag: "bcrypt-cost", usage: "bcrypt cost used for password hashes", set: intSetting(func(c *Config) *int { return &c.Auth.BcryptCost })},
+	{env: "GYM_SMTP_HOST", flag: "smtp-host", usage: "SMTP host, mail is written to stdout when empty", set: stringSetting(func(c *Config) *string { return &c.Mail.SMTPHost })},
+	{env: "GYM_SMTP_PORT", flag: "smtp-port", usage: "SMTP port", set: intSetting(func(c *Config) *int { return &c.Mail.SMTPPort })},
+	{env: "GYM_SMTP_USERNAME", flag: "smtp-username", usage: "SMTP username", set: stringSetting(func(c *Config) *string { return &c.Mail.SMTPUsername })},
+	{env: "GYM_SMTP_PASSWORD", flag: "smtp-password", usage: "SMTP password", set: stringSetting(func(c *Config) *string { return &c.Mail.SMTPPassword })},
+	{env: "GYM_MAIL_SENDER", flag: "mail-sender", usage: "From address of outgoing mail", set: stringSetting(func(c *Config) *string { return &c.Mail.Sender })},
+	{env: "GYM_TRASH_RETENTION", flag: "trash-retention", usage: "how long deleted workouts stay restorable", set: durationSetting(func(c *Config) *time.Duration { return &c.Trash.Retention })},
+	{env: "GYM_TRASH_PURGE_INTERVAL", flag: "trash-purge-interval", usage: "how often expired trash is purged", set: durationSetting(func(c *Config) *time.Duration { return &c.Trash.PurgeInterval })},
+	{env: "GYM_LOAD_ACWR_LOW", flag: "load-acwr-low", usage: "acute:chronic workload ratio below which load is flagged low", set: floatSetting(func(c *Config) *float64 { return &c.Load.ACWRLow })},
+	{env: "GYM_LOAD_ACWR_HIGH", flag: "load-acwr-high", usage: "acute:chronic workload ratio above which load is flagged high", set: floatSetting(func(c *Config) *float64 { return &c.Load.ACWRHigh })},
+	{env: "GYM_LOG_LEVEL", flag: "log-level", usage: "log level: debug, info, warn or error", set: stringSetting(func(c *Config) *string { return &c.LogLevel })},
+}
+
+// Load builds the configuration from defaults, an optional YAML file,
+// environment variables and command line flags, in increasing order of
+// precedence, and validates the result.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("go-server", flag.ContinueOnError)
+
+	configFile := os.Getenv("GYM_CONFIG_FILE")
+	fs.StringVar(&configFile, "config", configFile, "path to a YAML configuration file")
+
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		name := s.flag
+		fs.Func(name, s.usage, func(value string) error {
+			flagValues[name] = value
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if configFile != "" {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("config: read file %w", err)
+		}
+
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config: parse file %w", err)
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err := s.set(cfg, value); err != nil {
+			return nil, fmt.Errorf("config: %s: %w", s.env, err)
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := flagValues[s.flag]
+		if !ok {
+			continue
+		}
+		if err := s.set(cfg, value); err != nil {
+			return nil, fmt.Errorf("config: -%s: %w", s.flag, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server port must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.AdminPort >= 0 && c.Server.AdminPort <= 65535, "admin port must be between 0 and 65535, got %d", c.Server.AdminPort)
+	check(c.Server.AdminPort == 0 || c.Server.AdminPort != c.Server.Port, "admin port must differ from server port")
+	check(c.Server.ReadTimeout > 0, "server read timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "server write timeout must be positive")
+	check(c.Server.IdleTimeout > 0, "server idle timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server shutdown timeout must be positive")
+
+	check(c.Database.DSN != "", "database dsn is required")
+	check(c.Database.MaxOpenConns >= 0, "database max open conns cannot be negative")
+	check(c.Database.MaxIdleConns >= 0, "database max idle conns cannot be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database max idle conns (%d) cannot exceed max open conns (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database conn max lifetime cannot be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database conn max idle time cannot be negative")
+
+	check(c.Auth.AccessTokenTTL > 0, "access token ttl must be positive")
+	check(c.Auth.RefreshTokenTTL > c.Auth.AccessTokenTTL, "refresh token ttl must be longer than access token ttl")
+	check(c.Auth.PasswordResetTokenTTL > 0, "password reset token ttl must be positive")
+	check(c.Auth.ActivationTokenTTL > 0, "activation token ttl must be positive")
+	check(c.Auth.BcryptCost >= bcrypt.MinCost && c.Auth.BcryptCost <= bcrypt.MaxCost,
+		"bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c.Auth.BcryptCost)
+
+	check(c.Mail.SMTPHost == "" || (c.Mail.SMTPPort > 0 && c.Mail.SMTPPort <= 65535), "smtp port must be between 1 and 65535, got %d", c.Mail.SMTPPort)
+	check(c.Mail.Sender != "", "mail sender is required")
+
+	check(c.Trash.Retention > 0, "trash retention must be positive")
+	check(c.Trash.PurgeInterval > 0, "trash purge interval must be positive")
+
+	check(c.Load.ACWRLow > 0, "load acwr low must be positive")
+	check(c.Load.ACWRHigh > c.Load.ACWRLow, "load acwr high must be above acwr low")
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log level must be one of debug, info, warn or error, got %q", c.LogLevel))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: invalid configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
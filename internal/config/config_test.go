@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(`
+server:
+  port: 9000
+  read_timeout: 5s
+database:
+  dsn: "host=file"
+auth:
+  bcrypt_cost: 10
+log_level: debug
+`), 0o600)
+	require.NoError(t, err)
+
+	t.Setenv("GYM_CONFIG_FILE", file)
+	t.Setenv("GYM_DB_DSN", "host=env")
+	t.Setenv("GYM_BCRYPT_COST", "11")
+
+	cfg, err := Load([]string{"-bcrypt-cost", "13"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 9000, cfg.Server.Port, "file overrides defaults")
+	assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, 30*time.Second, cfg.Server.WriteTimeout, "unset values keep defaults")
+	assert.Equal(t, "host=env", cfg.Database.DSN, "env overrides file")
+	assert.Equal(t, 13, cfg.Auth.BcryptCost, "flags override env")
+	assert.Equal(t, "debug", cfg.LogLevel)
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "defaults are valid", args: nil},
+		{name: "invalid port", args: []string{"-port", "0"}, wantErr: "server port"},
+		{name: "bcrypt cost too low", args: []string{"-bcrypt-cost", "2"}, wantErr: "bcrypt cost"},
+		{name: "refresh shorter than access", args: []string{"-access-token-ttl", "48h", "-refresh-token-ttl", "24h"}, wantErr: "refresh token ttl"},
+		{name: "idle above open conns", args: []string{"-db-max-open-conns", "5", "-db-max-idle-conns", "10"}, wantErr: "max idle conns"},
+		{name: "unknown log level", args: []string{"-log-level", "verbose"}, wantErr: "log level"},
+		{name: "zero trash retention", args: []string{"-trash-retention", "0s"}, wantErr: "trash retention"},
+		{name: "acwr band inverted", args: []string{"-load-acwr-low", "1.6"}, wantErr: "acwr high"},
+		{name: "malformed duration", args: []string{"-read-timeout", "soon"}, wantErr: "read-timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"go-server/internal/utils"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc reports a dependency as healthy by returning nil before ctx
+// expires.
+type CheckFunc func(ctx context.Context) error
+
+type CheckResult struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
+
+type Checker struct {
+	timeout time.Duration
+	checks  []namedCheck
+	logger  *slog.Logger
+}
+
+func NewChecker(timeout time.Duration, logger *slog.Logger) *Checker {
+	return &Checker{
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// Register adds a readiness check. It is not safe to call once the checker
+// is serving requests.
+func (c *Checker) Register(name string, check CheckFunc) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Run executes every check concurrently, each bounded by the checker's
+// timeout, and reports whether all of them passed.
+func (c *Checker) Run(ctx context.Context) (map[string]CheckResult, bool) {
+	results := make(map[string]CheckResult, len(c.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := runCheck(checkCtx, nc.check)
+			result := CheckResult{Status: StatusUp, LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[nc.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		if result.Status != StatusUp {
+			ready = false
+		}
+	}
+
+	return results, ready
+}
+
+// runCheck returns once ctx is done even if check ignores the context, so a
+// hung dependency cannot hold the probe open past its timeout.
+func runCheck(ctx context.Context, check CheckFunc) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HandleLive only tells the orchestrator the process is able to serve HTTP;
+// dependencies are deliberately not checked so a database outage does not
+// get the process restarted.
+func (c *Checker) HandleLive(w http.ResponseWriter, r *http.Request) {
+	utils.WriterJSON(w, http.StatusOK, utils.Envelope{"status": StatusUp})
+}
+
+func (c *Checker) HandleReady(w http.ResponseWriter, r *http.Request) {
+	results, ready := c.Run(r.Context())
+
+	status, code := StatusUp, http.StatusOK
+	if !ready {
+		status, code = StatusDown, http.StatusServiceUnavailable
+		for name, result := range results {
+			if result.Status != StatusUp {
+				c.logger.WarnContext(r.Context(), "readiness check failed", "check", name, "error", result.Error)
+			}
+		}
+	}
+
+	utils.WriterJSON(w, code, utils.Envelope{"status": status, "checks": results})
+}
@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readyResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func TestHandleReady(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name       string
+		checks     map[string]CheckFunc
+		wantCode   int
+		wantStatus map[string]string
+	}{
+		{
+			name: "all up",
+			checks: map[string]CheckFunc{
+				"database": func(ctx context.Context) error { return nil },
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: map[string]string{"database": StatusUp},
+		},
+		{
+			name: "one down",
+			checks: map[string]CheckFunc{
+				"database":   func(ctx context.Context) error { return errors.New("connection refused") },
+				"migrations": func(ctx context.Context) error { return nil },
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: map[string]string{"database": StatusDown, "migrations": StatusUp},
+		},
+		{
+			name: "hung check times out",
+			checks: map[string]CheckFunc{
+				"database": func(ctx context.Context) error {
+					time.Sleep(time.Second)
+					return nil
+				},
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: map[string]string{"database": StatusDown},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(50*time.Millisecond, logger)
+			for name, check := range tt.checks {
+				checker.Register(name, check)
+			}
+
+			rr := httptest.NewRecorder()
+			checker.HandleReady(rr, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+			assert.Equal(t, tt.wantCode, rr.Code)
+
+			var body readyResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+			require.Len(t, body.Checks, len(tt.wantStatus))
+			for name, status := range tt.wantStatus {
+				assert.Equal(t, status, body.Checks[name].Status, name)
+			}
+		})
+	}
+}
+
+func TestHandleLive(t *testing.T) {
+	checker := NewChecker(time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	checker.Register("database", func(ctx context.Context) error { return errors.New("down") })
+
+	rr := httptest.NewRecorder()
+	checker.HandleLive(rr, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,23 @@
+package mailer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterMailer(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewWriterMailer(&buf, "no-reply@example.com")
+
+	err := mailer.Send(Message{To: "lifter@example.com", Subject: "Reset your password", Body: "token: ABC"})
+	require.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "From: no-reply@example.com\r\n")
+	assert.Contains(t, out, "To: lifter@example.com\r\n")
+	assert.Contains(t, out, "Subject: Reset your password\r\n")
+	assert.Contains(t, out, "\r\n\r\ntoken: ABC")
+}
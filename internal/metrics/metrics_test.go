@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentLabelsByRoutePattern(t *testing.T) {
+	m := New(nil)
+
+	router := chi.NewRouter()
+	router.Use(m.Instrument)
+	router.Get("/workout/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/workout/1", "/workout/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	m.AuthFailure("invalid_token")
+	m.WorkoutCreated()
+
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body, err := io.ReadAll(rr.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `gym_http_requests_total{method="GET",route="/workout/{id}",status="404"} 2`)
+	assert.Contains(t, string(body), `gym_http_requests_in_flight 0`)
+	assert.Contains(t, string(body), `gym_auth_failures_total{reason="invalid_token"} 1`)
+	assert.Contains(t, string(body), `gym_workouts_created_total 1`)
+}
+
+func TestNilMetricsIsNoop(t *testing.T) {
+	var m *Metrics
+	m.AuthFailure("invalid_token")
+	m.WorkoutCreated()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	assert.NotNil(t, m.Instrument(handler))
+}
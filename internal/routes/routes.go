@@ -2,28 +2,103 @@ package routes
 
 import (
 	"go-server/internal/app"
+	"go-server/internal/utils"
+	"go-server/middleware"
+	"net/http"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func SetupRoutes(app *app.Application) *chi.Mux {
 	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(middleware.AccessLog(app.Logger))
+	router.Use(app.Metrics.Instrument)
+	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		utils.NotFound(w, r, "the requested resource could not be found")
+	})
+	router.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteError(w, r, http.StatusMethodNotAllowed, utils.CodeMethodNotAllowed, r.Method+" is not supported for this resource")
+	})
 
 	router.Group(func(router chi.Router) {
 		router.Use(app.UserMiddleware.Authenticate)
 
-		router.With(app.UserMiddleware.RequireUser).Get("/workout/{id}", app.WorkoutHandler.HandleGetWorkoutById)
+		router.Get("/workout/{id}", app.WorkoutHandler.HandleGetWorkoutById)
+		router.With(app.UserMiddleware.RequireUser).Get("/workouts", app.WorkoutHandler.HandleListWorkouts)
+		router.With(app.UserMiddleware.RequireUser).Get("/workouts/trash", app.WorkoutHandler.HandleListTrash)
+		router.With(app.UserMiddleware.RequireActivatedUser).Post("/workouts/{id}/restore", app.WorkoutHandler.HandleRestoreWorkout)
+		router.With(app.UserMiddleware.RequireUser).Post("/workouts/{id}/save-as-template", app.TemplateHandler.HandleSaveWorkoutAsTemplate)
+
+		router.With(app.UserMiddleware.RequireUser).Get("/templates", app.TemplateHandler.HandleListTemplates)
+		router.With(app.UserMiddleware.RequireUser).Post("/templates", app.TemplateHandler.HandleCreateTemplate)
+		router.With(app.UserMiddleware.RequireUser).Get("/templates/{id}", app.TemplateHandler.HandleGetTemplate)
+		router.With(app.UserMiddleware.RequireUser).Put("/templates/{id}", app.TemplateHandler.HandleUpdateTemplate)
+		router.With(app.UserMiddleware.RequireUser).Delete("/templates/{id}", app.TemplateHandler.HandleDeleteTemplate)
+		router.With(app.UserMiddleware.RequireUser).Put("/templates/{id}/share", app.TemplateHandler.HandleShareTemplate)
+		router.With(app.UserMiddleware.RequireUser).Delete("/templates/{id}/share", app.TemplateHandler.HandleUnshareTemplate)
+		router.With(app.UserMiddleware.RequireActivatedUser).Post("/templates/{id}/start", app.TemplateHandler.HandleStartWorkout)
+		router.Get("/templates/shared/{code}", app.TemplateHandler.HandleGetSharedTemplate)
+		router.With(app.UserMiddleware.RequireActivatedUser).Post("/templates/shared/{code}/start", app.TemplateHandler.HandleStartSharedWorkout)
+
+		router.With(app.UserMiddleware.RequireUser).Get("/programs", app.ProgramHandler.HandleListPrograms)
+		router.With(app.UserMiddleware.RequireUser).Post("/programs", app.ProgramHandler.HandleCreateProgram)
+		router.With(app.UserMiddleware.RequireUser).Get("/programs/{id}", app.ProgramHandler.HandleGetProgram)
+		router.With(app.UserMiddleware.RequireUser).Delete("/programs/{id}", app.ProgramHandler.HandleDeleteProgram)
+		router.With(app.UserMiddleware.RequireUser).Post("/programs/{id}/enrollments", app.ProgramHandler.HandleEnroll)
+		router.With(app.UserMiddleware.RequireUser).Get("/enrollments/{id}", app.ProgramHandler.HandleGetEnrollment)
+		router.With(app.UserMiddleware.RequireUser).Delete("/enrollments/{id}", app.ProgramHandler.HandleDeleteEnrollment)
+		router.With(app.UserMiddleware.RequireActivatedUser).Post("/enrollments/{id}/sessions/{sessionId}/start", app.ProgramHandler.HandleStartSession)
+		router.With(app.UserMiddleware.RequireActivatedUser).Put("/enrollments/{id}/sessions/{sessionId}/workout", app.ProgramHandler.HandleLogSession)
 
-		router.Post("/workouts", app.WorkoutHandler.HandleCreateWorkout)
+		router.With(app.UserMiddleware.RequireActivatedUser).Post("/workouts", app.WorkoutHandler.HandleCreateWorkout)
 	
-		router.Put("/workout", app.WorkoutHandler.HandleUpdateWorkout)
+		router.With(app.UserMiddleware.RequireActivatedUser).Put("/workout", app.WorkoutHandler.HandleUpdateWorkout)
 	
-		router.Delete("/workout/{id}", app.WorkoutHandler.HandleDelete)
+		router.With(app.UserMiddleware.RequireActivatedUser).Delete("/workout/{id}", app.WorkoutHandler.HandleDelete)
+		router.With(app.UserMiddleware.RequireActivatedUser).Patch("/workouts/{id}", app.WorkoutHandler.HandlePatchWorkout)
+		router.With(app.UserMiddleware.RequireActivatedUser).Post("/workouts/{id}/entries", app.WorkoutHandler.HandleCreateEntry)
+		router.With(app.UserMiddleware.RequireActivatedUser).Put("/workouts/{id}/entries/order", app.WorkoutHandler.HandleReorderEntries)
+		router.With(app.UserMiddleware.RequireActivatedUser).Patch("/workouts/{id}/entries/{entryId}", app.WorkoutHandler.HandlePatchEntry)
+		router.With(app.UserMiddleware.RequireActivatedUser).Delete("/workouts/{id}/entries/{entryId}", app.WorkoutHandler.HandleDeleteEntry)
+
+		router.Get("/exercises", app.ExerciseHandler.HandleListExercises)
+		router.Get("/exercises/{id}", app.ExerciseHandler.HandleGetExerciseById)
+		router.With(app.UserMiddleware.RequireUser).Post("/exercises", app.ExerciseHandler.HandleCreateExercise)
+		router.With(app.UserMiddleware.RequireUser).Put("/exercises/{id}", app.ExerciseHandler.HandleUpdateExercise)
+		router.With(app.UserMiddleware.RequireUser).Delete("/exercises/{id}", app.ExerciseHandler.HandleDeleteExercise)
+		router.With(app.UserMiddleware.RequireUser).Get("/exercises/{id}/records", app.RecordHandler.HandleGetExerciseRecords)
+		router.With(app.UserMiddleware.RequireUser).Get("/exercises/{id}/next-target", app.RecordHandler.HandleGetNextTarget)
+
+		router.With(app.UserMiddleware.RequireUser).Get("/users/me/records", app.RecordHandler.HandleGetMyRecords)
+		router.With(app.UserMiddleware.RequireUser).Get("/users/me/enrollments", app.ProgramHandler.HandleListEnrollments)
+		router.With(app.UserMiddleware.RequireUser).Get("/users/me/schedule", app.ProgramHandler.HandleGetSchedule)
+		router.With(app.UserMiddleware.RequireUser).Get("/users/me/stats", app.StatsHandler.HandleGetMyStats)
+		router.With(app.UserMiddleware.RequireUser).Get("/users/me/load", app.StatsHandler.HandleGetMyLoad)
+		router.With(app.UserMiddleware.RequireUser).Get("/users/me/exercises/{id}/progress", app.RecordHandler.HandleGetExerciseProgress)
+		router.With(app.UserMiddleware.RequireUser).Get("/users/me", app.UserHandler.HandleGetCurrentUser)
+		router.With(app.UserMiddleware.RequireUser).Patch("/users/me", app.UserHandler.HandleUpdateCurrentUser)
+		router.With(app.UserMiddleware.RequireUser).Put("/users/me/password", app.UserHandler.HandleChangePassword)
+		router.With(app.UserMiddleware.RequireUser).Post("/users/me/activation-token", app.UserHandler.HandleResendActivation)
+		router.With(app.UserMiddleware.RequireUser).Delete("/users/me", app.UserHandler.HandleDeleteCurrentUser)
+		router.With(app.UserMiddleware.RequireUser).Put("/users/{username}/follow", app.FollowHandler.HandleFollow)
+		router.With(app.UserMiddleware.RequireUser).Delete("/users/{username}/follow", app.FollowHandler.HandleUnfollow)
+
+		router.With(app.UserMiddleware.RequireUser).Delete("/token", app.TokenHandler.HandleDeleteToken)
+		router.With(app.UserMiddleware.RequireUser).Get("/tokens", app.TokenHandler.HandleListTokens)
+		router.With(app.UserMiddleware.RequireUser).Delete("/tokens", app.TokenHandler.HandleDeleteAllTokens)
 	})
 
-	router.Get("/health", app.HealthCheck)
+	router.Get("/health", app.Health.HandleLive)
+	router.Get("/health/live", app.Health.HandleLive)
+	router.Get("/health/ready", app.Health.HandleReady)
 	router.Post("/user", app.UserHandler.HandleCreateUser)
+	router.Put("/users/activated", app.UserHandler.HandleActivateUser)
+	router.Get("/users/{username}", app.UserHandler.HandleGetUserByUsername)
 	router.Post("/token/authentication", app.TokenHandler.HandleCreateToken)
+	router.Post("/token/refresh", app.TokenHandler.HandleRefreshToken)
+	router.Post("/password-reset", app.PasswordHandler.HandleRequestPasswordReset)
+	router.Put("/password-reset", app.PasswordHandler.HandleResetPassword)
 
 	return router
 }
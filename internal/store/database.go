@@ -1,20 +1,27 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"go-server/internal/config"
 	"io/fs"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
 )
 
-func Open() (*sql.DB, error) {
-	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres sslmode=disable port=5432")
+func Open(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("pgx", cfg.DSN)
 	if err != nil {
 		return nil, fmt.Errorf("db: open %w", err)
 	}
 
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("db: ping %w", err)
 	}
@@ -45,3 +52,15 @@ func Migrate(db *sql.DB, dir string) error {
 	}
 	return nil
 }
+
+// MigrationVersions reports the schema version applied to db next to the
+// latest version embedded in migrationFS.
+func MigrationVersions(ctx context.Context, db *sql.DB, migrationFS fs.FS) (current, latest int64, err error) {
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrationFS)
+	if err != nil {
+		return 0, 0, fmt.Errorf("migrate: %w", err)
+	}
+
+	// the provider does not own db, so it is deliberately not closed here
+	return provider.GetVersions(ctx)
+}
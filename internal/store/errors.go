@@ -0,0 +1,74 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrNotFound       = errors.New("record not found")
+	ErrConflict       = errors.New("record conflicts with an existing one")
+	ErrEditConflict   = errors.New("record was modified concurrently")
+	ErrCheckViolation = errors.New("value violates a check constraint")
+)
+
+const (
+	pgUniqueViolation     = "23505"
+	pgCheckViolation      = "23514"
+	pgForeignKeyViolation = "23503"
+)
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
+// isForeignKeyViolation reports whether err is a violation of the named
+// foreign key constraint.
+func isForeignKeyViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation && pgErr.ConstraintName == constraint
+}
+
+// translateError maps driver errors onto the package's sentinel errors so
+// callers never need to know about database/sql or pgx. The constraint name
+// is kept in the message for logs.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation, pgForeignKeyViolation:
+		return fmt.Errorf("%w: %s", ErrConflict, pgErr.ConstraintName)
+	case pgCheckViolation:
+		return fmt.Errorf("%w: %s", ErrCheckViolation, pgErr.ConstraintName)
+	default:
+		return err
+	}
+}
+
+// requireRowsAffected turns an UPDATE or DELETE that matched nothing into
+// ErrNotFound.
+func requireRowsAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
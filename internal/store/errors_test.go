@@ -0,0 +1,44 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+	undefinedTable := &pgconn.PgError{Code: "42P01"}
+	connectionReset := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "no rows", err: sql.ErrNoRows, want: ErrNotFound},
+		{name: "wrapped no rows", err: fmt.Errorf("scan: %w", sql.ErrNoRows), want: ErrNotFound},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505", ConstraintName: "exercises_name_key"}, want: ErrConflict},
+		{name: "foreign key violation", err: &pgconn.PgError{Code: "23503", ConstraintName: "program_sessions_template_id_fkey"}, want: ErrConflict},
+		{name: "check violation", err: &pgconn.PgError{Code: "23514", ConstraintName: "workouts_duration_check"}, want: ErrCheckViolation},
+		{name: "other pg error passes through", err: undefinedTable, want: undefinedTable},
+		{name: "unrelated error passes through", err: connectionReset, want: connectionReset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, translateError(tt.err), tt.want)
+		})
+	}
+
+	assert.NoError(t, translateError(nil))
+}
+
+func TestDuplicateErrorsAreConflicts(t *testing.T) {
+	assert.ErrorIs(t, ErrDuplicateExercise, ErrConflict)
+	assert.ErrorIs(t, ErrDuplicateUsername, ErrConflict)
+	assert.ErrorIs(t, ErrDuplicateEmail, ErrConflict)
+}
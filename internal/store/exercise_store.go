@@ -0,0 +1,431 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-server/internal/validator"
+	"strings"
+	"time"
+)
+
+const (
+	MovementTypeReps  = "reps"
+	MovementTypeTimed = "timed"
+)
+
+var (
+	ErrDuplicateExercise = fmt.Errorf("exercise name already exists: %w", ErrConflict)
+	ErrDuplicateAlias    = fmt.Errorf("exercise alias already names another exercise: %w", ErrConflict)
+	ErrExerciseInUse     = fmt.Errorf("exercise is used by other users: %w", ErrConflict)
+	ErrUnknownExercise   = errors.New("exercise does not exist")
+)
+
+// Exercise is a catalog entry. UserID is the user who added it; exercises
+// without one belong to the shared catalog.
+type Exercise struct {
+	ID                    int       `json:"id"`
+	UserID                *int      `json:"user_id"`
+	Name                  string    `json:"name"`
+	Aliases               []string  `json:"aliases"`
+	PrimaryMuscleGroups   []string  `json:"primary_muscle_groups"`
+	SecondaryMuscleGroups []string  `json:"secondary_muscle_groups"`
+	Equipment             string    `json:"equipment"`
+	MovementType          string    `json:"movement_type"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+func ValidateExercise(v *validator.Validator, exercise *Exercise) {
+	v.Check(validator.NotBlank(exercise.Name), "name", "is required")
+	v.Check(validator.MaxChars(exercise.Name, 255), "name", "cannot exceed 255 characters")
+	v.Check(validator.PermittedValue(exercise.MovementType, MovementTypeReps, MovementTypeTimed), "movement_type", "must be either reps or timed")
+	v.Check(validator.MaxChars(exercise.Equipment, 100), "equipment", "cannot exceed 100 characters")
+}
+
+type ExerciseStore interface {
+	CreateExercise(exercise *Exercise) error
+	GetExerciseByID(id int64) (*Exercise, error)
+	ListExercises() ([]*Exercise, error)
+	UpdateExercise(exercise *Exercise) error
+	DeleteExercise(id int64) error
+	ResolveExercise(name string) (*Exercise, error)
+}
+
+type PostgresExerciseStore struct {
+	db *sql.DB
+}
+
+func NewPostgresExerciseStore(db *sql.DB) *PostgresExerciseStore {
+	return &PostgresExerciseStore{db: db}
+}
+
+// NormalizeExerciseName lowercases the name and collapses whitespace so that
+// "Bench  Press" and "bench press" resolve to the same exercise.
+func NormalizeExerciseName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func normalizeExercise(exercise *Exercise) {
+	exercise.Name = strings.Join(strings.Fields(exercise.Name), " ")
+
+	aliases := make([]string, 0, len(exercise.Aliases))
+	for _, alias := range exercise.Aliases {
+		alias = NormalizeExerciseName(alias)
+		if alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	exercise.Aliases = aliases
+
+	if exercise.PrimaryMuscleGroups == nil {
+		exercise.PrimaryMuscleGroups = []string{}
+	}
+	if exercise.SecondaryMuscleGroups == nil {
+		exercise.SecondaryMuscleGroups = []string{}
+	}
+}
+
+// jsonStringArray scans a TEXT[] column selected through to_json, which keeps
+// array decoding independent of the pgx type map.
+type jsonStringArray []string
+
+func (a *jsonStringArray) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*a = []string{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into string array", src)
+	}
+
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+const exerciseColumns = `
+	id, user_id, name, to_json(aliases), to_json(primary_muscle_groups), to_json(secondary_muscle_groups),
+	COALESCE(equipment, ''), movement_type, created_at, updated_at
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExercise(row rowScanner) (*Exercise, error) {
+	exercise := &Exercise{}
+	err := row.Scan(
+		&exercise.ID,
+		&exercise.UserID,
+		&exercise.Name,
+		(*jsonStringArray)(&exercise.Aliases),
+		(*jsonStringArray)(&exercise.PrimaryMuscleGroups),
+		(*jsonStringArray)(&exercise.SecondaryMuscleGroups),
+		&exercise.Equipment,
+		&exercise.MovementType,
+		&exercise.CreatedAt,
+		&exercise.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return exercise, nil
+}
+
+// lockExerciseNames serializes catalog writes and rejects a name or alias
+// that already resolves to another exercise, since resolveExercise could
+// then link entries to either of them.
+func lockExerciseNames(tx *sql.Tx, exercise *Exercise) error {
+	_, err := tx.Exec(`LOCK TABLE exercises IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM exercises WHERE id <> $1 AND $2 = ANY(aliases)),
+			EXISTS (SELECT 1 FROM exercises WHERE id <> $1 AND (lower(name) = ANY($3::text[]) OR aliases && $3::text[]))
+	`
+
+	var nameTaken, aliasTaken bool
+	err = tx.QueryRow(query, exercise.ID, NormalizeExerciseName(exercise.Name), exercise.Aliases).Scan(&nameTaken, &aliasTaken)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case nameTaken:
+		return ErrDuplicateExercise
+	case aliasTaken:
+		return ErrDuplicateAlias
+	}
+
+	return nil
+}
+
+func (pg *PostgresExerciseStore) CreateExercise(exercise *Exercise) error {
+	normalizeExercise(exercise)
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockExerciseNames(tx, exercise)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO exercises (user_id, name, aliases, primary_muscle_groups, secondary_muscle_groups, equipment, movement_type)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = tx.QueryRow(
+		query,
+		exercise.UserID,
+		exercise.Name,
+		exercise.Aliases,
+		exercise.PrimaryMuscleGroups,
+		exercise.SecondaryMuscleGroups,
+		exercise.Equipment,
+		exercise.MovementType,
+	).Scan(&exercise.ID, &exercise.CreatedAt, &exercise.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateExercise
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresExerciseStore) GetExerciseByID(id int64) (*Exercise, error) {
+	query := `SELECT ` + exerciseColumns + ` FROM exercises WHERE id = $1`
+
+	exercise, err := scanExercise(pg.db.QueryRow(query, id))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return exercise, nil
+}
+
+func (pg *PostgresExerciseStore) ListExercises() ([]*Exercise, error) {
+	query := `SELECT ` + exerciseColumns + ` FROM exercises ORDER BY name`
+
+	rows, err := pg.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []*Exercise{}
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, exercise)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exercises, nil
+}
+
+func (pg *PostgresExerciseStore) UpdateExercise(exercise *Exercise) error {
+	normalizeExercise(exercise)
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockExerciseNames(tx, exercise)
+	if err != nil {
+		return err
+	}
+
+	err = requireUnshared(tx, int64(exercise.ID))
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE exercises
+		SET name = $1, aliases = $2, primary_muscle_groups = $3, secondary_muscle_groups = $4,
+			equipment = NULLIF($5, ''), movement_type = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7
+		RETURNING user_id, created_at, updated_at
+	`
+
+	err = tx.QueryRow(
+		query,
+		exercise.Name,
+		exercise.Aliases,
+		exercise.PrimaryMuscleGroups,
+		exercise.SecondaryMuscleGroups,
+		exercise.Equipment,
+		exercise.MovementType,
+		exercise.ID,
+	).Scan(&exercise.UserID, &exercise.CreatedAt, &exercise.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateExercise
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresExerciseStore) DeleteExercise(id int64) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = requireUnshared(tx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM exercises WHERE id = $1`, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return tx.Commit()
+}
+
+// requireUnshared locks the exercise and returns ErrExerciseInUse when
+// anyone but its owner has logged, planned or prescribed it, since entries
+// resolve to other users' exercises by name. The row lock also holds off
+// new references until the transaction ends.
+// exerciseSharedCondition is true for an exercise row e that anyone but its
+// owner has logged, planned or prescribed.
+const exerciseSharedCondition = `(
+	EXISTS (
+		SELECT 1 FROM workout_entries we
+		INNER JOIN workouts w ON w.id = we.workout_id
+		WHERE we.exercise_id = e.id AND w.user_id IS DISTINCT FROM e.user_id
+	) OR EXISTS (
+		SELECT 1 FROM workout_template_entries te
+		INNER JOIN workout_templates t ON t.id = te.template_id
+		WHERE te.exercise_id = e.id AND t.user_id IS DISTINCT FROM e.user_id
+	) OR EXISTS (
+		SELECT 1 FROM program_prescriptions pp
+		INNER JOIN program_sessions ps ON ps.id = pp.session_id
+		INNER JOIN programs p ON p.id = ps.program_id
+		WHERE pp.exercise_id = e.id AND p.user_id IS DISTINCT FROM e.user_id
+	) OR EXISTS (
+		SELECT 1 FROM training_maxes tm
+		INNER JOIN program_enrollments pe ON pe.id = tm.enrollment_id
+		WHERE tm.exercise_id = e.id AND pe.user_id IS DISTINCT FROM e.user_id
+	)
+)`
+
+func requireUnshared(tx *sql.Tx, id int64) error {
+	err := tx.QueryRow(`SELECT id FROM exercises WHERE id = $1 FOR UPDATE`, id).Scan(&id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	var shared bool
+	err = tx.QueryRow(`SELECT `+exerciseSharedCondition+` FROM exercises e WHERE e.id = $1`, id).Scan(&shared)
+	if err != nil {
+		return err
+	}
+	if shared {
+		return ErrExerciseInUse
+	}
+
+	return nil
+}
+
+func (pg *PostgresExerciseStore) ResolveExercise(name string) (*Exercise, error) {
+	exercise, err := resolveExercise(pg.db, name)
+	if err != nil {
+		return nil, err
+	}
+	if exercise == nil {
+		return nil, ErrNotFound
+	}
+
+	return exercise, nil
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// resolveExercise returns nil without an error when nothing matches, since
+// free-text entries are allowed to name exercises outside the catalog.
+func resolveExercise(q queryRower, name string) (*Exercise, error) {
+	normalized := NormalizeExerciseName(name)
+	if normalized == "" {
+		return nil, nil
+	}
+
+	query := `
+		SELECT ` + exerciseColumns + `
+		FROM exercises
+		WHERE lower(name) = $1 OR $1 = ANY(aliases)
+		ORDER BY lower(name) = $1 DESC
+		LIMIT 1
+	`
+
+	exercise, err := scanExercise(q.QueryRow(query, normalized))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return exercise, nil
+}
+
+// resolveEntryExercise links an entry to the catalog: a known exercise ID
+// fills in the canonical name, otherwise the free-text name is looked up by
+// name and aliases. Unknown names are kept as free text.
+func resolveEntryExercise(q queryRower, entry *WorkoutEntry) error {
+	if entry.ExerciseID != nil {
+		var name string
+		err := q.QueryRow(`SELECT name FROM exercises WHERE id = $1`, *entry.ExerciseID).Scan(&name)
+		if err == sql.ErrNoRows {
+			return ErrUnknownExercise
+		}
+		if err != nil {
+			return err
+		}
+		if entry.ExerciseName == "" {
+			entry.ExerciseName = name
+		}
+		return nil
+	}
+
+	exercise, err := resolveExercise(q, entry.ExerciseName)
+	if err != nil {
+		return err
+	}
+	if exercise != nil {
+		entry.ExerciseID = &exercise.ID
+	}
+
+	return nil
+}
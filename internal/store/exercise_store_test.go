@@ -0,0 +1,104 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveExercise(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE exercises CASCADE")
+	require.NoError(t, err)
+
+	store := NewPostgresExerciseStore(db)
+
+	bench := &Exercise{
+		Name:                "Bench  Press",
+		Aliases:             []string{"BP", " Flat Bench "},
+		PrimaryMuscleGroups: []string{"chest"},
+		MovementType:        MovementTypeReps,
+	}
+	require.NoError(t, store.CreateExercise(bench))
+	assert.Equal(t, "Bench Press", bench.Name)
+	assert.Equal(t, []string{"bp", "flat bench"}, bench.Aliases)
+
+	err = store.CreateExercise(&Exercise{Name: "bench press", MovementType: MovementTypeReps})
+	assert.ErrorIs(t, err, ErrDuplicateExercise)
+
+	// names and aliases must resolve to a single exercise
+	err = store.CreateExercise(&Exercise{Name: "BP", MovementType: MovementTypeReps})
+	assert.ErrorIs(t, err, ErrDuplicateExercise)
+	err = store.CreateExercise(&Exercise{Name: "Incline Bench", Aliases: []string{"flat bench"}, MovementType: MovementTypeReps})
+	assert.ErrorIs(t, err, ErrDuplicateAlias)
+	err = store.CreateExercise(&Exercise{Name: "Chest Press", Aliases: []string{"Bench Press"}, MovementType: MovementTypeReps})
+	assert.ErrorIs(t, err, ErrDuplicateAlias)
+
+	deadlift := &Exercise{Name: "Deadlift", MovementType: MovementTypeReps}
+	require.NoError(t, store.CreateExercise(deadlift))
+	deadlift.Aliases = []string{"bp"}
+	assert.ErrorIs(t, store.UpdateExercise(deadlift), ErrDuplicateAlias)
+	bench.Aliases = append(bench.Aliases, "barbell bench")
+	require.NoError(t, store.UpdateExercise(bench))
+
+	tests := []struct {
+		name   string
+		input  string
+		wantID int
+	}{
+		{name: "canonical name", input: "Bench Press", wantID: bench.ID},
+		{name: "different case and spacing", input: "  bench   PRESS", wantID: bench.ID},
+		{name: "alias", input: "bp", wantID: bench.ID},
+		{name: "unknown", input: "Squat", wantID: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exercise, err := store.ResolveExercise(tt.input)
+			if tt.wantID == 0 {
+				assert.ErrorIs(t, err, ErrNotFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantID, exercise.ID)
+		})
+	}
+}
+
+func TestExerciseInUse(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, exercises CASCADE")
+	require.NoError(t, err)
+
+	exerciseStore := NewPostgresExerciseStore(db)
+	workoutStore := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "inventor")
+	other := createTestUser(t, db, "borrower")
+
+	press := &Exercise{UserID: IntPtr(owner.ID), Name: "Landmine Press", MovementType: MovementTypeReps}
+	require.NoError(t, exerciseStore.CreateExercise(press))
+
+	_, err = workoutStore.CreateWorkout(&Workout{UserID: owner.ID, Title: "Own", Entries: []WorkoutEntry{
+		{ExerciseName: "landmine press", Sets: 3, Reps: IntPtr(8), OrderIndex: 0},
+	}})
+	require.NoError(t, err)
+
+	press.Equipment = "barbell"
+	require.NoError(t, exerciseStore.UpdateExercise(press), "the owner's own entries do not block changes")
+
+	// free-text entries resolve to any user's exercise by name
+	_, err = workoutStore.CreateWorkout(&Workout{UserID: other.ID, Title: "Borrowed", Entries: []WorkoutEntry{
+		{ExerciseName: "Landmine Press", Sets: 3, Reps: IntPtr(8), OrderIndex: 0},
+	}})
+	require.NoError(t, err)
+
+	press.Name = "Renamed"
+	assert.ErrorIs(t, exerciseStore.UpdateExercise(press), ErrExerciseInUse)
+	assert.ErrorIs(t, exerciseStore.DeleteExercise(int64(press.ID)), ErrExerciseInUse)
+	assert.ErrorIs(t, exerciseStore.DeleteExercise(int64(press.ID)+1000), ErrNotFound)
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"database/sql"
+)
+
+type FollowStore interface {
+	Follow(followerID, followeeID int) error
+	Unfollow(followerID, followeeID int) error
+	IsFollowing(followerID, followeeID int) (bool, error)
+}
+
+type PostgresFollowStore struct {
+	db *sql.DB
+}
+
+func NewPostgresFollowStore(db *sql.DB) *PostgresFollowStore {
+	return &PostgresFollowStore{db: db}
+}
+
+// Follow is idempotent: following someone twice is not an error.
+func (pg *PostgresFollowStore) Follow(followerID, followeeID int) error {
+	query := `
+		INSERT INTO follows (follower_id, followee_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := pg.db.Exec(query, followerID, followeeID)
+	return translateError(err)
+}
+
+func (pg *PostgresFollowStore) Unfollow(followerID, followeeID int) error {
+	query := `
+		DELETE FROM follows
+		WHERE follower_id = $1 AND followee_id = $2
+	`
+
+	_, err := pg.db.Exec(query, followerID, followeeID)
+	return translateError(err)
+}
+
+func (pg *PostgresFollowStore) IsFollowing(followerID, followeeID int) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM follows
+			WHERE follower_id = $1 AND followee_id = $2
+		)
+	`
+
+	var following bool
+	err := pg.db.QueryRow(query, followerID, followeeID).Scan(&following)
+	return following, err
+}
@@ -0,0 +1,686 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-server/internal/validator"
+	"math"
+	"slices"
+	"time"
+)
+
+// Program is a multi-week plan. Each session puts one of the author's
+// templates on a day of a week, optionally prescribing weights as a
+// percentage of the enrolled lifter's training max.
+type Program struct {
+	ID          int              `json:"id"`
+	UserID      int              `json:"user_id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Weeks       int              `json:"weeks"`
+	Public      bool             `json:"public"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Sessions    []ProgramSession `json:"sessions"`
+}
+
+type ProgramSession struct {
+	ID            int            `json:"id"`
+	Week          int            `json:"week"`
+	Day           int            `json:"day"`
+	TemplateID    int            `json:"template_id"`
+	Prescriptions []Prescription `json:"prescriptions"`
+}
+
+// Prescription and TrainingMax keep the exercise name so they still read
+// right after the exercise itself is deleted and ExerciseID becomes nil.
+type Prescription struct {
+	ExerciseID           *int    `json:"exercise_id"`
+	ExerciseName         string  `json:"exercise_name"`
+	PercentOfTrainingMax float64 `json:"percent_of_training_max"`
+}
+
+type Enrollment struct {
+	ID            int           `json:"id"`
+	ProgramID     int           `json:"program_id"`
+	UserID        int           `json:"user_id"`
+	StartDate     time.Time     `json:"start_date"`
+	TrainingMaxes []TrainingMax `json:"training_maxes"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type TrainingMax struct {
+	ExerciseID   *int    `json:"exercise_id"`
+	ExerciseName string  `json:"exercise_name"`
+	Weight       float64 `json:"weight"`
+}
+
+// PlannedSession is a program session placed on the calendar of one
+// enrollment. WorkoutID is set once a logged workout fulfils it.
+type PlannedSession struct {
+	EnrollmentID int       `json:"enrollment_id"`
+	ProgramID    int       `json:"program_id"`
+	SessionID    int       `json:"session_id"`
+	Week         int       `json:"week"`
+	Day          int       `json:"day"`
+	Date         time.Time `json:"date"`
+	TemplateID   int       `json:"template_id"`
+	WorkoutID    *int      `json:"workout_id"`
+}
+
+type Adherence struct {
+	Due       int      `json:"due"`
+	Completed int      `json:"completed"`
+	Rate      *float64 `json:"rate"`
+}
+
+const (
+	maxProgramWeeks       = 52
+	maxPercentTrainingMax = 150
+	// prescribedWeightIncrement is the plate increment prescribed weights
+	// are rounded to.
+	prescribedWeightIncrement = 2.5
+)
+
+var (
+	ErrUnknownTemplate = errors.New("template does not exist")
+	ErrProgramInUse    = fmt.Errorf("other users are enrolled in the program: %w", ErrConflict)
+)
+
+func ValidateProgram(v *validator.Validator, program *Program) {
+	v.Check(validator.NotBlank(program.Name), "name", "is required")
+	v.Check(validator.MaxChars(program.Name, 255), "name", "cannot exceed 255 characters")
+	v.Check(validator.MaxChars(program.Description, 10000), "description", "cannot exceed 10000 characters")
+	v.Check(validator.Between(program.Weeks, 1, maxProgramWeeks), "weeks", "must be between 1 and 52")
+	v.Check(len(program.Sessions) > 0, "sessions", "must contain at least one session")
+	v.Check(len(program.Sessions) <= program.Weeks*7, "sessions", "cannot contain more than one session per day")
+
+	slots := make([]int, 0, len(program.Sessions))
+	for i, session := range program.Sessions {
+		prefix := fmt.Sprintf("sessions[%d].", i)
+		v.Check(validator.Between(session.Week, 1, program.Weeks), prefix+"week", "must be within the program's weeks")
+		v.Check(validator.Between(session.Day, 1, 7), prefix+"day", "must be between 1 and 7")
+		v.Check(session.TemplateID > 0, prefix+"template_id", "is required")
+		slots = append(slots, session.Week*7+session.Day)
+
+		exerciseIDs := make([]int, 0, len(session.Prescriptions))
+		for j, prescription := range session.Prescriptions {
+			prescriptionPrefix := fmt.Sprintf("%sprescriptions[%d].", prefix, j)
+			v.Check(prescription.PercentOfTrainingMax > 0 && prescription.PercentOfTrainingMax <= maxPercentTrainingMax, prescriptionPrefix+"percent_of_training_max", "must be above 0 and at most 150")
+			if prescription.ExerciseID == nil || *prescription.ExerciseID < 1 {
+				v.AddError(prescriptionPrefix+"exercise_id", "is required")
+				continue
+			}
+			exerciseIDs = append(exerciseIDs, *prescription.ExerciseID)
+		}
+		v.Check(validator.Unique(exerciseIDs), prefix+"prescriptions", "cannot prescribe the same exercise twice")
+	}
+	v.Check(validator.Unique(slots), "sessions", "cannot schedule two sessions on the same day")
+}
+
+func ValidateEnrollment(v *validator.Validator, enrollment *Enrollment) {
+	v.Check(!enrollment.StartDate.IsZero(), "start_date", "is required")
+
+	exerciseIDs := make([]int, 0, len(enrollment.TrainingMaxes))
+	for i, trainingMax := range enrollment.TrainingMaxes {
+		prefix := fmt.Sprintf("training_maxes[%d].", i)
+		v.Check(trainingMax.Weight > 0 && trainingMax.Weight <= maxEntryWeight, prefix+"weight", "must be above 0 and at most 999.99")
+		v.Check(validator.MaxDecimalPlaces(trainingMax.Weight, 2), prefix+"weight", "cannot have more than 2 decimal places")
+		if trainingMax.ExerciseID == nil || *trainingMax.ExerciseID < 1 {
+			v.AddError(prefix+"exercise_id", "is required")
+			continue
+		}
+		exerciseIDs = append(exerciseIDs, *trainingMax.ExerciseID)
+	}
+	v.Check(validator.Unique(exerciseIDs), "training_maxes", "cannot contain the same exercise twice")
+}
+
+func (p *Program) Session(id int) (*ProgramSession, bool) {
+	for i := range p.Sessions {
+		if p.Sessions[i].ID == id {
+			return &p.Sessions[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// SessionDate is the calendar day a session falls on: day 1 of week 1 is
+// the start date.
+func (e *Enrollment) SessionDate(session ProgramSession) time.Time {
+	return e.StartDate.AddDate(0, 0, (session.Week-1)*7+session.Day-1)
+}
+
+// PlanSessions lays the program out on the enrollment's calendar in date
+// order. completed maps session IDs to the workouts that fulfilled them.
+func PlanSessions(program *Program, enrollment *Enrollment, completed map[int]int) []PlannedSession {
+	planned := make([]PlannedSession, 0, len(program.Sessions))
+	for _, session := range program.Sessions {
+		plannedSession := PlannedSession{
+			EnrollmentID: enrollment.ID,
+			ProgramID:    program.ID,
+			SessionID:    session.ID,
+			Week:         session.Week,
+			Day:          session.Day,
+			Date:         enrollment.SessionDate(session),
+			TemplateID:   session.TemplateID,
+		}
+		if workoutID, ok := completed[session.ID]; ok {
+			plannedSession.WorkoutID = &workoutID
+		}
+		planned = append(planned, plannedSession)
+	}
+
+	slices.SortFunc(planned, func(a, b PlannedSession) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	return planned
+}
+
+// ComputeAdherence counts sessions dated on or before today and how many of
+// those have a logged workout. Rate is nil while nothing is due.
+func ComputeAdherence(planned []PlannedSession, today time.Time) Adherence {
+	var adherence Adherence
+	for _, session := range planned {
+		if session.Date.After(today) {
+			continue
+		}
+		adherence.Due++
+		if session.WorkoutID != nil {
+			adherence.Completed++
+		}
+	}
+
+	if adherence.Due > 0 {
+		rate := float64(adherence.Completed) / float64(adherence.Due)
+		adherence.Rate = &rate
+	}
+
+	return adherence
+}
+
+// PrescribedWorkout starts a workout from the session's template and sets
+// the weight of every prescribed exercise the lifter has a training max for.
+func PrescribedWorkout(template *WorkoutTemplate, session *ProgramSession, enrollment *Enrollment) *Workout {
+	workout := template.NewWorkout(enrollment.UserID)
+
+	trainingMaxes := make(map[int]float64, len(enrollment.TrainingMaxes))
+	for _, trainingMax := range enrollment.TrainingMaxes {
+		if trainingMax.ExerciseID != nil {
+			trainingMaxes[*trainingMax.ExerciseID] = trainingMax.Weight
+		}
+	}
+
+	for _, prescription := range session.Prescriptions {
+		if prescription.ExerciseID == nil {
+			continue
+		}
+		trainingMax, ok := trainingMaxes[*prescription.ExerciseID]
+		if !ok {
+			continue
+		}
+		weight := prescribedWeight(trainingMax, prescription.PercentOfTrainingMax)
+
+		for i := range workout.Entries {
+			entry := &workout.Entries[i]
+			if entry.ExerciseID != nil && *entry.ExerciseID == *prescription.ExerciseID {
+				entry.Weight = &weight
+			}
+		}
+	}
+
+	return workout
+}
+
+func prescribedWeight(trainingMax, percent float64) float64 {
+	weight := math.Round(trainingMax*percent/100/prescribedWeightIncrement) * prescribedWeightIncrement
+	return math.Min(weight, maxEntryWeight)
+}
+
+type ProgramStore interface {
+	CreateProgram(*Program) error
+	GetProgramByID(id int64) (*Program, error)
+	ListPrograms(userID int) ([]*Program, error)
+	DeleteProgram(id int64) error
+	CreateEnrollment(*Enrollment) error
+	GetEnrollmentByID(id int64) (*Enrollment, error)
+	ListEnrollments(userID int) ([]*Enrollment, error)
+	DeleteEnrollment(id int64) error
+	CompletedSessions(enrollmentID int64) (map[int]int, error)
+	LogSession(enrollmentID, sessionID int64, workoutID int) error
+	StartSession(template *WorkoutTemplate, session *ProgramSession, enrollment *Enrollment) (*Workout, error)
+}
+
+type PostgresProgramStore struct {
+	db *sql.DB
+}
+
+func NewPostgresProgramStore(db *sql.DB) *PostgresProgramStore {
+	return &PostgresProgramStore{db: db}
+}
+
+// CreateProgram stores the program with its sessions. Every session must
+// use one of the author's own templates, otherwise ErrUnknownTemplate is
+// returned.
+func (pg *PostgresProgramStore) CreateProgram(program *Program) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	templateIDs := []int{}
+	for _, session := range program.Sessions {
+		if !slices.Contains(templateIDs, session.TemplateID) {
+			templateIDs = append(templateIDs, session.TemplateID)
+		}
+	}
+
+	var owned int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM workout_templates WHERE id = ANY($1) AND user_id = $2`, templateIDs, program.UserID).Scan(&owned)
+	if err != nil {
+		return err
+	}
+	if owned != len(templateIDs) {
+		return ErrUnknownTemplate
+	}
+
+	query := `
+		INSERT INTO programs (user_id, name, description, weeks, public)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err = tx.QueryRow(query, program.UserID, program.Name, program.Description, program.Weeks, program.Public).Scan(&program.ID, &program.CreatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	for i := range program.Sessions {
+		session := &program.Sessions[i]
+
+		query := `
+			INSERT INTO program_sessions (program_id, week, day, template_id)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`
+
+		err = tx.QueryRow(query, program.ID, session.Week, session.Day, session.TemplateID).Scan(&session.ID)
+		if err != nil {
+			return translateError(err)
+		}
+
+		for j := range session.Prescriptions {
+			prescription := &session.Prescriptions[j]
+			// an unknown id leaves the name empty and fails the foreign key
+			query := `
+				INSERT INTO program_prescriptions (session_id, exercise_id, exercise_name, percent_of_training_max)
+				VALUES ($1, $2, COALESCE((SELECT name FROM exercises WHERE id = $2), ''), $3)
+				RETURNING exercise_name
+			`
+
+			err = tx.QueryRow(query, session.ID, prescription.ExerciseID, prescription.PercentOfTrainingMax).Scan(&prescription.ExerciseName)
+			if isForeignKeyViolation(err, "program_prescriptions_exercise_id_fkey") {
+				return ErrUnknownExercise
+			}
+			if err != nil {
+				return translateError(err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresProgramStore) GetProgramByID(id int64) (*Program, error) {
+	program := &Program{}
+
+	query := `
+		SELECT id, user_id, name, description, weeks, public, created_at
+		FROM programs
+		WHERE id = $1
+	`
+
+	err := pg.db.QueryRow(query, id).Scan(
+		&program.ID,
+		&program.UserID,
+		&program.Name,
+		&program.Description,
+		&program.Weeks,
+		&program.Public,
+		&program.CreatedAt,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	sessions, err := pg.programSessions([]int64{id})
+	if err != nil {
+		return nil, err
+	}
+	program.Sessions = sessions[program.ID]
+
+	return program, nil
+}
+
+// ListPrograms returns the user's own programs and everyone's public ones.
+func (pg *PostgresProgramStore) ListPrograms(userID int) ([]*Program, error) {
+	query := `
+		SELECT id, user_id, name, description, weeks, public, created_at
+		FROM programs
+		WHERE user_id = $1 OR public
+		ORDER BY name, id
+	`
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	programs := []*Program{}
+	ids := []int64{}
+	for rows.Next() {
+		program := &Program{}
+		err := rows.Scan(
+			&program.ID,
+			&program.UserID,
+			&program.Name,
+			&program.Description,
+			&program.Weeks,
+			&program.Public,
+			&program.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+		ids = append(ids, int64(program.ID))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sessions, err := pg.programSessions(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, program := range programs {
+		program.Sessions = sessions[program.ID]
+	}
+
+	return programs, nil
+}
+
+func (pg *PostgresProgramStore) programSessions(programIDs []int64) (map[int][]ProgramSession, error) {
+	query := `
+		SELECT s.program_id, s.id, s.week, s.day, s.template_id, p.exercise_id, p.exercise_name, p.percent_of_training_max
+		FROM program_sessions s
+		LEFT JOIN program_prescriptions p ON p.session_id = s.id
+		WHERE s.program_id = ANY($1)
+		ORDER BY s.program_id, s.week, s.day, p.exercise_id
+	`
+
+	rows, err := pg.db.Query(query, programIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := map[int][]ProgramSession{}
+	for rows.Next() {
+		var programID int
+		var session ProgramSession
+		var exerciseID *int
+		var exerciseName sql.NullString
+		var percent sql.NullFloat64
+		err := rows.Scan(&programID, &session.ID, &session.Week, &session.Day, &session.TemplateID, &exerciseID, &exerciseName, &percent)
+		if err != nil {
+			return nil, err
+		}
+
+		// rows of the same session are adjacent, one per prescription
+		programSessions := sessions[programID]
+		if n := len(programSessions); n == 0 || programSessions[n-1].ID != session.ID {
+			session.Prescriptions = []Prescription{}
+			programSessions = append(programSessions, session)
+		}
+		if percent.Valid {
+			last := &programSessions[len(programSessions)-1]
+			last.Prescriptions = append(last.Prescriptions, Prescription{
+				ExerciseID:           exerciseID,
+				ExerciseName:         exerciseName.String,
+				PercentOfTrainingMax: percent.Float64,
+			})
+		}
+		sessions[programID] = programSessions
+	}
+
+	return sessions, rows.Err()
+}
+
+// DeleteProgram removes the program with its own enrollments. While other
+// users are enrolled it returns ErrProgramInUse instead, the row lock holds
+// off new enrollments until the program is gone.
+func (pg *PostgresProgramStore) DeleteProgram(id int64) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var owner int
+	err = tx.QueryRow(`SELECT user_id FROM programs WHERE id = $1 FOR UPDATE`, id).Scan(&owner)
+	if err != nil {
+		return translateError(err)
+	}
+
+	var enrolled bool
+	query := `SELECT EXISTS (SELECT 1 FROM program_enrollments WHERE program_id = $1 AND user_id <> $2)`
+	err = tx.QueryRow(query, id, owner).Scan(&enrolled)
+	if err != nil {
+		return err
+	}
+	if enrolled {
+		return ErrProgramInUse
+	}
+
+	_, err = tx.Exec(`DELETE FROM programs WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresProgramStore) CreateEnrollment(enrollment *Enrollment) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO program_enrollments (program_id, user_id, start_date)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	err = tx.QueryRow(query, enrollment.ProgramID, enrollment.UserID, enrollment.StartDate).Scan(&enrollment.ID, &enrollment.CreatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	for i := range enrollment.TrainingMaxes {
+		trainingMax := &enrollment.TrainingMaxes[i]
+		query := `
+			INSERT INTO training_maxes (enrollment_id, exercise_id, exercise_name, weight)
+			VALUES ($1, $2, COALESCE((SELECT name FROM exercises WHERE id = $2), ''), $3)
+			RETURNING exercise_name
+		`
+
+		err = tx.QueryRow(query, enrollment.ID, trainingMax.ExerciseID, trainingMax.Weight).Scan(&trainingMax.ExerciseName)
+		if isForeignKeyViolation(err, "training_maxes_exercise_id_fkey") {
+			return ErrUnknownExercise
+		}
+		if err != nil {
+			return translateError(err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresProgramStore) GetEnrollmentByID(id int64) (*Enrollment, error) {
+	enrollments, err := pg.queryEnrollments(`WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(enrollments) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return enrollments[0], nil
+}
+
+func (pg *PostgresProgramStore) ListEnrollments(userID int) ([]*Enrollment, error) {
+	return pg.queryEnrollments(`WHERE user_id = $1`, userID)
+}
+
+func (pg *PostgresProgramStore) queryEnrollments(where string, arg any) ([]*Enrollment, error) {
+	query := `
+		SELECT id, program_id, user_id, start_date, created_at
+		FROM program_enrollments
+	` + where + `
+		ORDER BY start_date, id
+	`
+
+	rows, err := pg.db.Query(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrollments := []*Enrollment{}
+	byID := map[int]*Enrollment{}
+	ids := []int64{}
+	for rows.Next() {
+		enrollment := &Enrollment{TrainingMaxes: []TrainingMax{}}
+		err := rows.Scan(&enrollment.ID, &enrollment.ProgramID, &enrollment.UserID, &enrollment.StartDate, &enrollment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, enrollment)
+		byID[enrollment.ID] = enrollment
+		ids = append(ids, int64(enrollment.ID))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return enrollments, nil
+	}
+
+	maxRows, err := pg.db.Query(`SELECT enrollment_id, exercise_id, exercise_name, weight FROM training_maxes WHERE enrollment_id = ANY($1) ORDER BY exercise_id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer maxRows.Close()
+
+	for maxRows.Next() {
+		var enrollmentID int
+		var trainingMax TrainingMax
+		if err := maxRows.Scan(&enrollmentID, &trainingMax.ExerciseID, &trainingMax.ExerciseName, &trainingMax.Weight); err != nil {
+			return nil, err
+		}
+		byID[enrollmentID].TrainingMaxes = append(byID[enrollmentID].TrainingMaxes, trainingMax)
+	}
+
+	return enrollments, maxRows.Err()
+}
+
+func (pg *PostgresProgramStore) DeleteEnrollment(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM program_enrollments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return requireRowsAffected(result)
+}
+
+// CompletedSessions maps the enrollment's fulfilled session IDs to their
+// workouts. Workouts in the trash do not count.
+func (pg *PostgresProgramStore) CompletedSessions(enrollmentID int64) (map[int]int, error) {
+	query := `
+		SELECT l.session_id, l.workout_id
+		FROM program_session_logs l
+		INNER JOIN workouts w ON w.id = l.workout_id
+		WHERE l.enrollment_id = $1 AND w.deleted_at IS NULL
+	`
+
+	rows, err := pg.db.Query(query, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completed := map[int]int{}
+	for rows.Next() {
+		var sessionID, workoutID int
+		if err := rows.Scan(&sessionID, &workoutID); err != nil {
+			return nil, err
+		}
+		completed[sessionID] = workoutID
+	}
+
+	return completed, rows.Err()
+}
+
+// LogSession records that the workout fulfilled the planned session,
+// replacing any earlier workout. A workout can fulfil only one session, so
+// reusing it returns ErrConflict.
+func (pg *PostgresProgramStore) LogSession(enrollmentID, sessionID int64, workoutID int) error {
+	return logSession(pg.db, enrollmentID, sessionID, workoutID)
+}
+
+func logSession(db execer, enrollmentID, sessionID int64, workoutID int) error {
+	query := `
+		INSERT INTO program_session_logs (enrollment_id, session_id, workout_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (enrollment_id, session_id) DO UPDATE SET workout_id = EXCLUDED.workout_id
+	`
+
+	_, err := db.Exec(query, enrollmentID, sessionID, workoutID)
+	return translateError(err)
+}
+
+// StartSession creates the session's prescribed workout and logs it against
+// the session in one transaction, so a failure leaves neither behind.
+func (pg *PostgresProgramStore) StartSession(template *WorkoutTemplate, session *ProgramSession, enrollment *Enrollment) (*Workout, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	workout := PrescribedWorkout(template, session, enrollment)
+	err = insertWorkout(tx, workout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = logSession(tx, int64(enrollment.ID), int64(session.ID), workout.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return workout, nil
+}
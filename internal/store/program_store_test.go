@@ -0,0 +1,210 @@
+package store
+
+import (
+	"go-server/internal/validator"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateProgram(t *testing.T) {
+	program := &Program{
+		Name:  "5/3/1",
+		Weeks: 2,
+		Sessions: []ProgramSession{
+			{Week: 1, Day: 1, TemplateID: 1, Prescriptions: []Prescription{{ExerciseID: IntPtr(3), PercentOfTrainingMax: 65}, {PercentOfTrainingMax: 50}}},
+			{Week: 3, Day: 8, TemplateID: 1},
+			{Week: 1, Day: 1, TemplateID: 2, Prescriptions: []Prescription{
+				{ExerciseID: IntPtr(3), PercentOfTrainingMax: 70},
+				{ExerciseID: IntPtr(3), PercentOfTrainingMax: 200},
+			}},
+		},
+	}
+
+	v := validator.New()
+	ValidateProgram(v, program)
+
+	assert.Equal(t, map[string]string{
+		"sessions[0].prescriptions[1].exercise_id":             "is required",
+		"sessions[1].week":                                     "must be within the program's weeks",
+		"sessions[1].day":                                      "must be between 1 and 7",
+		"sessions[2].prescriptions[1].percent_of_training_max": "must be above 0 and at most 150",
+		"sessions[2].prescriptions":                            "cannot prescribe the same exercise twice",
+		"sessions":                                             "cannot schedule two sessions on the same day",
+	}, v.Errors)
+}
+
+func TestPlanSessions(t *testing.T) {
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	program := &Program{
+		ID:    1,
+		Weeks: 2,
+		Sessions: []ProgramSession{
+			{ID: 11, Week: 2, Day: 1, TemplateID: 5},
+			{ID: 10, Week: 1, Day: 3, TemplateID: 5},
+			{ID: 12, Week: 2, Day: 7, TemplateID: 6},
+		},
+	}
+	enrollment := &Enrollment{ID: 4, ProgramID: 1, StartDate: start}
+
+	planned := PlanSessions(program, enrollment, map[int]int{10: 99})
+
+	require.Len(t, planned, 3)
+	assert.Equal(t, 10, planned[0].SessionID)
+	assert.Equal(t, start.AddDate(0, 0, 2), planned[0].Date)
+	assert.Equal(t, IntPtr(99), planned[0].WorkoutID)
+	assert.Equal(t, start.AddDate(0, 0, 7), planned[1].Date)
+	assert.Nil(t, planned[1].WorkoutID)
+	assert.Equal(t, start.AddDate(0, 0, 13), planned[2].Date)
+
+	tests := []struct {
+		name  string
+		today time.Time
+		want  Adherence
+	}{
+		{name: "before start", today: start, want: Adherence{}},
+		{name: "first session due", today: start.AddDate(0, 0, 2), want: Adherence{Due: 1, Completed: 1, Rate: FloatPtr(1)}},
+		{name: "second session missed", today: start.AddDate(0, 0, 8), want: Adherence{Due: 2, Completed: 1, Rate: FloatPtr(0.5)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ComputeAdherence(planned, tt.today))
+		})
+	}
+}
+
+func TestPrescribedWorkout(t *testing.T) {
+	template := &WorkoutTemplate{
+		Name: "Squat day",
+		Entries: []TemplateEntry{
+			{ExerciseID: IntPtr(3), ExerciseName: "Squat", TargetSets: 3, TargetReps: IntPtr(5), TargetWeight: FloatPtr(100)},
+			{ExerciseID: IntPtr(4), ExerciseName: "Bench Press", TargetSets: 3, TargetReps: IntPtr(5), TargetWeight: FloatPtr(60)},
+			{ExerciseName: "Plank", TargetSets: 2, TargetDurationSeconds: IntPtr(60)},
+		},
+	}
+	session := &ProgramSession{Prescriptions: []Prescription{
+		{ExerciseID: IntPtr(3), PercentOfTrainingMax: 85},
+		{ExerciseID: IntPtr(4), PercentOfTrainingMax: 70},
+	}}
+	enrollment := &Enrollment{UserID: 7, TrainingMaxes: []TrainingMax{{ExerciseID: IntPtr(3), Weight: 143}}}
+
+	workout := PrescribedWorkout(template, session, enrollment)
+
+	assert.Equal(t, 7, workout.UserID)
+	// 85% of 143 is 121.55, rounded to the nearest 2.5
+	assert.Equal(t, FloatPtr(122.5), workout.Entries[0].Weight)
+	// no training max for the bench press, so the template weight stays
+	assert.Equal(t, FloatPtr(60), workout.Entries[1].Weight)
+	assert.Nil(t, workout.Entries[2].Weight)
+}
+
+func TestProgramStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, exercises CASCADE")
+	require.NoError(t, err)
+
+	programStore := NewPostgresProgramStore(db)
+	templateStore := NewPostgresTemplateStore(db)
+	workoutStore := NewPostgresWorkoutStore(db)
+	coach := createTestUser(t, db, "coach")
+	lifter := createTestUser(t, db, "lifter")
+
+	squat := &Exercise{Name: "Squat", MovementType: MovementTypeReps}
+	require.NoError(t, NewPostgresExerciseStore(db).CreateExercise(squat))
+
+	template := &WorkoutTemplate{
+		UserID:  coach.ID,
+		Name:    "Squat day",
+		Entries: []TemplateEntry{{ExerciseID: IntPtr(squat.ID), ExerciseName: "Squat", TargetSets: 3, TargetReps: IntPtr(5)}},
+	}
+	require.NoError(t, templateStore.CreateTemplate(template))
+
+	program := &Program{
+		UserID: lifter.ID,
+		Name:   "Borrowed",
+		Weeks:  1,
+		Sessions: []ProgramSession{
+			{Week: 1, Day: 1, TemplateID: template.ID},
+		},
+	}
+	assert.ErrorIs(t, programStore.CreateProgram(program), ErrUnknownTemplate)
+
+	program.UserID = coach.ID
+	program.Name = "Squat cycle"
+	program.Public = true
+	program.Sessions = []ProgramSession{
+		{Week: 1, Day: 1, TemplateID: template.ID, Prescriptions: []Prescription{{ExerciseID: IntPtr(squat.ID), PercentOfTrainingMax: 70}}},
+		{Week: 1, Day: 4, TemplateID: template.ID},
+	}
+	require.NoError(t, programStore.CreateProgram(program))
+
+	stored, err := programStore.GetProgramByID(int64(program.ID))
+	require.NoError(t, err)
+	require.Len(t, stored.Sessions, 2)
+	assert.Equal(t, []Prescription{{ExerciseID: IntPtr(squat.ID), ExerciseName: "Squat", PercentOfTrainingMax: 70}}, stored.Sessions[0].Prescriptions)
+	assert.Empty(t, stored.Sessions[1].Prescriptions)
+
+	programs, err := programStore.ListPrograms(lifter.ID)
+	require.NoError(t, err)
+	require.Len(t, programs, 1)
+
+	enrollment := &Enrollment{
+		ProgramID:     program.ID,
+		UserID:        lifter.ID,
+		StartDate:     time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		TrainingMaxes: []TrainingMax{{ExerciseID: IntPtr(squat.ID), Weight: 150}},
+	}
+	require.NoError(t, programStore.CreateEnrollment(enrollment))
+
+	loaded, err := programStore.GetEnrollmentByID(int64(enrollment.ID))
+	require.NoError(t, err)
+	assert.True(t, enrollment.StartDate.Equal(loaded.StartDate))
+	assert.Equal(t, enrollment.TrainingMaxes, loaded.TrainingMaxes)
+
+	workout, err := programStore.StartSession(template, &stored.Sessions[0], loaded)
+	require.NoError(t, err)
+	assert.Equal(t, FloatPtr(105), workout.Entries[0].Weight)
+
+	sessionID := int64(stored.Sessions[0].ID)
+	assert.ErrorIs(t, programStore.LogSession(int64(enrollment.ID), int64(stored.Sessions[1].ID), workout.ID), ErrConflict)
+
+	completed, err := programStore.CompletedSessions(int64(enrollment.ID))
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{int(sessionID): workout.ID}, completed)
+
+	require.NoError(t, workoutStore.DeleteWorkout(int64(workout.ID), workout.Version))
+	completed, err = programStore.CompletedSessions(int64(enrollment.ID))
+	require.NoError(t, err)
+	assert.Empty(t, completed)
+
+	err = templateStore.DeleteTemplate(int64(template.ID))
+	assert.ErrorIs(t, err, ErrTemplateInUse)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	// deleting the exercise keeps the prescription and training max by name
+	_, err = db.Exec(`DELETE FROM exercises WHERE id = $1`, squat.ID)
+	require.NoError(t, err)
+	stored, err = programStore.GetProgramByID(int64(program.ID))
+	require.NoError(t, err)
+	assert.Equal(t, []Prescription{{ExerciseName: "Squat", PercentOfTrainingMax: 70}}, stored.Sessions[0].Prescriptions)
+	loaded, err = programStore.GetEnrollmentByID(int64(enrollment.ID))
+	require.NoError(t, err)
+	assert.Equal(t, []TrainingMax{{ExerciseName: "Squat", Weight: 150}}, loaded.TrainingMaxes)
+
+	assert.ErrorIs(t, programStore.DeleteProgram(int64(program.ID)), ErrProgramInUse)
+	require.NoError(t, programStore.DeleteEnrollment(int64(enrollment.ID)))
+
+	own := &Enrollment{ProgramID: program.ID, UserID: coach.ID, StartDate: enrollment.StartDate}
+	require.NoError(t, programStore.CreateEnrollment(own))
+	require.NoError(t, programStore.DeleteProgram(int64(program.ID)), "the author's own enrollments do not block the delete")
+	_, err = programStore.GetEnrollmentByID(int64(own.ID))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, programStore.DeleteProgram(int64(program.ID)), ErrNotFound)
+
+	require.NoError(t, templateStore.DeleteTemplate(int64(template.ID)), "templates are free again once no program uses them")
+}
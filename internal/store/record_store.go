@@ -0,0 +1,345 @@
+package store
+
+import (
+	"database/sql"
+	"go-server/internal/strength"
+	"time"
+)
+
+const (
+	RecordHeaviestWeight      = "heaviest_weight"
+	RecordMostRepsAtWeight    = "most_reps_at_weight"
+	RecordEstimated1RMEpley   = "estimated_1rm_epley"
+	RecordEstimated1RMBrzycki = "estimated_1rm_brzycki"
+	RecordLongestDuration     = "longest_duration"
+)
+
+type PersonalRecord struct {
+	ID              int       `json:"id"`
+	UserID          int       `json:"user_id"`
+	ExerciseID      int       `json:"exercise_id"`
+	ExerciseName    string    `json:"exercise_name"`
+	RecordType      string    `json:"record_type"`
+	Value           float64   `json:"value"`
+	Weight          *float64  `json:"weight"`
+	Reps            *int      `json:"reps"`
+	DurationSeconds *int      `json:"duration_seconds"`
+	WorkoutID       int       `json:"workout_id"`
+	WorkoutEntryID  int       `json:"workout_entry_id"`
+	AchievedAt      time.Time `json:"achieved_at"`
+}
+
+// RecordCandidate is a logged entry considered when computing records.
+type RecordCandidate struct {
+	WorkoutID       int
+	WorkoutEntryID  int
+	ExerciseID      int
+	Reps            *int
+	DurationSeconds *int
+	Weight          *float64
+	PerformedAt     time.Time
+}
+
+type RecordStore interface {
+	GetRecordsForUser(userID int) ([]*PersonalRecord, error)
+	GetRecordsForExercise(userID int, exerciseID int64) ([]*PersonalRecord, error)
+	GetExerciseHistory(userID int, exerciseID int64, sessions int) ([]strength.Session, error)
+	GetExerciseSessions(userID int, exerciseID int64, from, to *time.Time) ([]strength.Session, error)
+}
+
+type PostgresRecordStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRecordStore(db *sql.DB) *PostgresRecordStore {
+	return &PostgresRecordStore{db: db}
+}
+
+// ComputePersonalRecords walks candidates in chronological order and keeps,
+// per exercise and record type, the first entry that reached the best value.
+func ComputePersonalRecords(userID int, candidates []RecordCandidate) []*PersonalRecord {
+	type recordKey struct {
+		exerciseID int
+		recordType string
+		weight     float64
+	}
+
+	best := map[recordKey]*PersonalRecord{}
+	order := []recordKey{}
+
+	consider := func(key recordKey, value float64, candidate RecordCandidate) {
+		if value <= 0 {
+			return
+		}
+
+		current, ok := best[key]
+		if ok && current.Value >= value {
+			return
+		}
+		if !ok {
+			order = append(order, key)
+		}
+
+		best[key] = &PersonalRecord{
+			UserID:          userID,
+			ExerciseID:      candidate.ExerciseID,
+			RecordType:      key.recordType,
+			Value:           strength.Round(value, 2),
+			Weight:          candidate.Weight,
+			Reps:            candidate.Reps,
+			DurationSeconds: candidate.DurationSeconds,
+			WorkoutID:       candidate.WorkoutID,
+			WorkoutEntryID:  candidate.WorkoutEntryID,
+			AchievedAt:      candidate.PerformedAt,
+		}
+	}
+
+	for _, candidate := range candidates {
+		if candidate.DurationSeconds != nil {
+			key := recordKey{exerciseID: candidate.ExerciseID, recordType: RecordLongestDuration}
+			consider(key, float64(*candidate.DurationSeconds), candidate)
+		}
+
+		if candidate.Weight == nil {
+			continue
+		}
+		weight := *candidate.Weight
+
+		consider(recordKey{exerciseID: candidate.ExerciseID, recordType: RecordHeaviestWeight}, weight, candidate)
+
+		if candidate.Reps == nil {
+			continue
+		}
+		reps := *candidate.Reps
+
+		key := recordKey{exerciseID: candidate.ExerciseID, recordType: RecordMostRepsAtWeight, weight: weight}
+		consider(key, float64(reps), candidate)
+
+		key = recordKey{exerciseID: candidate.ExerciseID, recordType: RecordEstimated1RMEpley}
+		consider(key, strength.Epley(weight, reps), candidate)
+
+		key = recordKey{exerciseID: candidate.ExerciseID, recordType: RecordEstimated1RMBrzycki}
+		consider(key, strength.Brzycki(weight, reps), candidate)
+	}
+
+	records := make([]*PersonalRecord, 0, len(order))
+	for _, key := range order {
+		records = append(records, best[key])
+	}
+
+	return records
+}
+
+// recomputePersonalRecords rebuilds the records of the given exercises from
+// the user's remaining entries, so edits and deletes can lower a record too.
+// Rebuilds of one user are serialized so that concurrent writes neither
+// duplicate records nor miss each other's entries.
+func recomputePersonalRecords(tx *sql.Tx, userID int, exerciseIDs []int) error {
+	if len(exerciseIDs) == 0 {
+		return nil
+	}
+
+	// an advisory lock rather than a row lock on users, which would deadlock
+	// with the key share lock the workout insert already holds on that row
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM personal_records WHERE user_id = $1 AND exercise_id = ANY($2)`, userID, exerciseIDs)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT we.workout_id, we.id, we.exercise_id, we.reps, we.duration_seconds, we.weight, w.created_at
+		FROM workout_entries we
+		INNER JOIN workouts w ON w.id = we.workout_id
+		WHERE w.user_id = $1 AND we.exercise_id = ANY($2) AND w.deleted_at IS NULL
+		ORDER BY w.created_at, we.id
+	`
+
+	rows, err := tx.Query(query, userID, exerciseIDs)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	candidates := []RecordCandidate{}
+	for rows.Next() {
+		var candidate RecordCandidate
+		err := rows.Scan(
+			&candidate.WorkoutID,
+			&candidate.WorkoutEntryID,
+			&candidate.ExerciseID,
+			&candidate.Reps,
+			&candidate.DurationSeconds,
+			&candidate.Weight,
+			&candidate.PerformedAt,
+		)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, record := range ComputePersonalRecords(userID, candidates) {
+		query := `
+			INSERT INTO personal_records (user_id, exercise_id, record_type, value, weight, reps, duration_seconds, workout_id, workout_entry_id, achieved_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (user_id, exercise_id, record_type, weight_key) DO UPDATE
+			SET value = EXCLUDED.value, weight = EXCLUDED.weight, reps = EXCLUDED.reps,
+				duration_seconds = EXCLUDED.duration_seconds, workout_id = EXCLUDED.workout_id,
+				workout_entry_id = EXCLUDED.workout_entry_id, achieved_at = EXCLUDED.achieved_at
+		`
+
+		_, err = tx.Exec(query, record.UserID, record.ExerciseID, record.RecordType, record.Value, record.Weight, record.Reps, record.DurationSeconds, record.WorkoutID, record.WorkoutEntryID, record.AchievedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func entryExerciseIDs(entries []WorkoutEntry) []int {
+	seen := map[int]bool{}
+	ids := []int{}
+	for _, entry := range entries {
+		if entry.ExerciseID == nil || seen[*entry.ExerciseID] {
+			continue
+		}
+		seen[*entry.ExerciseID] = true
+		ids = append(ids, *entry.ExerciseID)
+	}
+
+	return ids
+}
+
+func (pg *PostgresRecordStore) GetRecordsForUser(userID int) ([]*PersonalRecord, error) {
+	return pg.queryRecords(`WHERE pr.user_id = $1`, userID)
+}
+
+func (pg *PostgresRecordStore) GetRecordsForExercise(userID int, exerciseID int64) ([]*PersonalRecord, error) {
+	return pg.queryRecords(`WHERE pr.user_id = $1 AND pr.exercise_id = $2`, userID, exerciseID)
+}
+
+// GetExerciseHistory returns the weighted sets of the user's last sessions
+// of the exercise, oldest first, for suggesting the next target.
+func (pg *PostgresRecordStore) GetExerciseHistory(userID int, exerciseID int64, sessions int) ([]strength.Session, error) {
+	query := `
+		WITH recent AS (
+			SELECT DISTINCT w.id, w.created_at
+			FROM workouts w
+			INNER JOIN workout_entries we ON we.workout_id = w.id
+			WHERE w.user_id = $1 AND we.exercise_id = $2 AND w.deleted_at IS NULL
+				AND we.weight IS NOT NULL AND we.reps IS NOT NULL
+			ORDER BY w.created_at DESC, w.id DESC
+			LIMIT $3
+		)
+		SELECT r.id, r.created_at, we.sets, we.reps, we.weight, COALESCE(we.rpe, 0)
+		FROM recent r
+		INNER JOIN workout_entries we ON we.workout_id = r.id
+		WHERE we.exercise_id = $2 AND we.weight IS NOT NULL AND we.reps IS NOT NULL
+		ORDER BY r.created_at, r.id, we.order_index
+	`
+
+	return pg.queryExerciseSessions(query, userID, exerciseID, sessions)
+}
+
+// GetExerciseSessions returns the weighted sets of every session of the
+// exercise created in [from, to), oldest first. Nil bounds are open.
+func (pg *PostgresRecordStore) GetExerciseSessions(userID int, exerciseID int64, from, to *time.Time) ([]strength.Session, error) {
+	query := `
+		SELECT w.id, w.created_at, we.sets, we.reps, we.weight, COALESCE(we.rpe, 0)
+		FROM workouts w
+		INNER JOIN workout_entries we ON we.workout_id = w.id
+		WHERE w.user_id = $1 AND we.exercise_id = $2 AND w.deleted_at IS NULL
+			AND we.weight IS NOT NULL AND we.reps IS NOT NULL
+			AND ($3::timestamptz IS NULL OR w.created_at >= $3)
+			AND ($4::timestamptz IS NULL OR w.created_at < $4)
+		ORDER BY w.created_at, w.id, we.order_index
+	`
+
+	return pg.queryExerciseSessions(query, userID, exerciseID, from, to)
+}
+
+// queryExerciseSessions groups rows of workout id, created_at, sets, reps,
+// weight and rpe into sessions. Rows of one workout must be adjacent.
+func (pg *PostgresRecordStore) queryExerciseSessions(query string, args ...any) ([]strength.Session, error) {
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []strength.Session{}
+	for rows.Next() {
+		var workoutID int
+		var performedAt time.Time
+		var set strength.Set
+		err := rows.Scan(&workoutID, &performedAt, &set.Sets, &set.Reps, &set.Weight, &set.RPE)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(history) == 0 || history[len(history)-1].WorkoutID != workoutID {
+			history = append(history, strength.Session{WorkoutID: workoutID, PerformedAt: performedAt})
+		}
+		last := &history[len(history)-1]
+		last.Sets = append(last.Sets, set)
+	}
+
+	return history, rows.Err()
+}
+
+func (pg *PostgresRecordStore) queryRecords(where string, args ...any) ([]*PersonalRecord, error) {
+	query := `
+		SELECT pr.id, pr.user_id, pr.exercise_id, e.name, pr.record_type, pr.value, pr.weight, pr.reps,
+			pr.duration_seconds, pr.workout_id, pr.workout_entry_id, pr.achieved_at
+		FROM personal_records pr
+		INNER JOIN exercises e ON e.id = pr.exercise_id
+		` + where + `
+		ORDER BY e.name, pr.record_type, pr.weight
+	`
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*PersonalRecord{}
+	for rows.Next() {
+		record := &PersonalRecord{}
+		err := rows.Scan(
+			&record.ID,
+			&record.UserID,
+			&record.ExerciseID,
+			&record.ExerciseName,
+			&record.RecordType,
+			&record.Value,
+			&record.Weight,
+			&record.Reps,
+			&record.DurationSeconds,
+			&record.WorkoutID,
+			&record.WorkoutEntryID,
+			&record.AchievedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputePersonalRecords(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2025, 1, d, 10, 0, 0, 0, time.UTC)
+	}
+
+	candidates := []RecordCandidate{
+		{WorkoutID: 1, WorkoutEntryID: 10, ExerciseID: 1, Reps: IntPtr(5), Weight: FloatPtr(100), PerformedAt: day(1)},
+		{WorkoutID: 2, WorkoutEntryID: 20, ExerciseID: 1, Reps: IntPtr(8), Weight: FloatPtr(100), PerformedAt: day(2)},
+		{WorkoutID: 3, WorkoutEntryID: 30, ExerciseID: 1, Reps: IntPtr(1), Weight: FloatPtr(120), PerformedAt: day(3)},
+		{WorkoutID: 4, WorkoutEntryID: 40, ExerciseID: 1, Reps: IntPtr(1), Weight: FloatPtr(120), PerformedAt: day(4)},
+		{WorkoutID: 4, WorkoutEntryID: 41, ExerciseID: 2, DurationSeconds: IntPtr(60), PerformedAt: day(4)},
+		{WorkoutID: 5, WorkoutEntryID: 50, ExerciseID: 2, DurationSeconds: IntPtr(90), PerformedAt: day(5)},
+	}
+
+	records := ComputePersonalRecords(7, candidates)
+
+	byKey := map[string]*PersonalRecord{}
+	for _, record := range records {
+		assert.Equal(t, 7, record.UserID)
+		key := record.RecordType
+		if record.RecordType == RecordMostRepsAtWeight {
+			key = fmt.Sprintf("%s@%v", key, *record.Weight)
+		}
+		byKey[key] = record
+	}
+
+	heaviest := byKey[RecordHeaviestWeight]
+	require.NotNil(t, heaviest)
+	assert.Equal(t, 120.0, heaviest.Value)
+	assert.Equal(t, 30, heaviest.WorkoutEntryID, "ties keep the earliest entry")
+
+	repsAt100 := byKey[RecordMostRepsAtWeight+"@100"]
+	require.NotNil(t, repsAt100)
+	assert.Equal(t, 8.0, repsAt100.Value)
+	assert.Equal(t, 20, repsAt100.WorkoutEntryID)
+
+	epley := byKey[RecordEstimated1RMEpley]
+	require.NotNil(t, epley)
+	assert.Equal(t, 126.67, epley.Value)
+	assert.Equal(t, 20, epley.WorkoutEntryID)
+
+	brzycki := byKey[RecordEstimated1RMBrzycki]
+	require.NotNil(t, brzycki)
+	assert.Equal(t, 124.14, brzycki.Value)
+
+	longest := byKey[RecordLongestDuration]
+	require.NotNil(t, longest)
+	assert.Equal(t, 2, longest.ExerciseID)
+	assert.Equal(t, 90.0, longest.Value)
+	assert.Equal(t, 50, longest.WorkoutEntryID)
+
+	assert.Len(t, records, 6)
+}
+
+func TestConcurrentRecordRebuilds(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, exercises CASCADE")
+	require.NoError(t, err)
+
+	user := createTestUser(t, db, "racer")
+	squat := &Exercise{Name: "Squat", MovementType: MovementTypeReps}
+	require.NoError(t, NewPostgresExerciseStore(db).CreateExercise(squat))
+
+	workoutStore := NewPostgresWorkoutStore(db)
+	const workouts = 8
+	errs := make(chan error, workouts)
+	for i := range workouts {
+		go func() {
+			_, err := workoutStore.CreateWorkout(&Workout{
+				UserID: user.ID,
+				Title:  fmt.Sprintf("Squat %d", i),
+				Entries: []WorkoutEntry{
+					{ExerciseID: IntPtr(squat.ID), Sets: 1, Reps: IntPtr(5), Weight: FloatPtr(float64(100 + i*10)), OrderIndex: 0},
+				},
+			})
+			errs <- err
+		}()
+	}
+	for range workouts {
+		require.NoError(t, <-errs)
+	}
+
+	records, err := NewPostgresRecordStore(db).GetRecordsForExercise(user.ID, int64(squat.ID))
+	require.NoError(t, err)
+
+	// one most-reps record per weight plus heaviest weight and both 1RMs
+	assert.Len(t, records, workouts+3)
+	for _, record := range records {
+		if record.RecordType == RecordHeaviestWeight {
+			assert.Equal(t, 170.0, record.Value)
+		}
+	}
+}
+
+// upStatements returns the statements of a migration's Up section.
+func upStatements(t *testing.T, path string) []string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	up, _, _ := strings.Cut(string(data), "-- +goose Down")
+	statements := []string{}
+	for _, block := range strings.Split(up, "-- +goose StatementBegin")[1:] {
+		statement, _, _ := strings.Cut(block, "-- +goose StatementEnd")
+		statements = append(statements, statement)
+	}
+
+	return statements
+}
+
+func TestPersonalRecordsBackfill(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, exercises CASCADE")
+	require.NoError(t, err)
+
+	user := createTestUser(t, db, "veteran")
+	exerciseStore := NewPostgresExerciseStore(db)
+	bench := &Exercise{Name: "Bench Press", MovementType: MovementTypeReps}
+	require.NoError(t, exerciseStore.CreateExercise(bench))
+	plank := &Exercise{Name: "Plank", MovementType: MovementTypeTimed}
+	require.NoError(t, exerciseStore.CreateExercise(plank))
+
+	workoutStore := NewPostgresWorkoutStore(db)
+	workouts := [][]WorkoutEntry{
+		{
+			{ExerciseID: IntPtr(bench.ID), Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 0},
+			{ExerciseID: IntPtr(plank.ID), Sets: 1, DurationSeconds: IntPtr(60), OrderIndex: 1},
+		},
+		{
+			{ExerciseID: IntPtr(bench.ID), Sets: 1, Reps: IntPtr(8), Weight: FloatPtr(100), OrderIndex: 0},
+			{ExerciseID: IntPtr(bench.ID), Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(120), OrderIndex: 1},
+			{ExerciseID: IntPtr(bench.ID), Sets: 1, Reps: IntPtr(40), Weight: FloatPtr(20), OrderIndex: 2},
+		},
+		{
+			{ExerciseID: IntPtr(bench.ID), Sets: 1, Reps: IntPtr(1), Weight: FloatPtr(120), OrderIndex: 0},
+			{ExerciseID: IntPtr(plank.ID), Sets: 1, DurationSeconds: IntPtr(90), OrderIndex: 1},
+		},
+	}
+	for i, entries := range workouts {
+		_, err := workoutStore.CreateWorkout(&Workout{UserID: user.ID, Title: fmt.Sprintf("Day %d", i), Entries: entries})
+		require.NoError(t, err)
+	}
+
+	recordStore := NewPostgresRecordStore(db)
+	want, err := recordStore.GetRecordsForUser(user.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, want)
+
+	for _, statement := range upStatements(t, "../../migrations/00022_personal_records_backfill.sql") {
+		_, err := db.Exec(statement)
+		require.NoError(t, err)
+	}
+
+	got, err := recordStore.GetRecordsForUser(user.ID)
+	require.NoError(t, err)
+	require.Len(t, got, len(want))
+	for i := range want {
+		// the backfill inserts new rows
+		got[i].ID = want[i].ID
+		assert.Equal(t, want[i], got[i])
+	}
+}
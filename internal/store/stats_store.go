@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"go-server/internal/trainingload"
+	"time"
+)
+
+const (
+	StatsBucketWeek  = "week"
+	StatsBucketMonth = "month"
+)
+
+// StatsFilter selects the user's workouts created in [From, To) and groups
+// them into week or month buckets.
+type StatsFilter struct {
+	UserID int
+	From   time.Time
+	To     time.Time
+	Bucket string
+}
+
+type Stats struct {
+	From                  time.Time            `json:"from"`
+	To                    time.Time            `json:"to"`
+	TotalSessions         int                  `json:"total_sessions"`
+	TotalDurationSeconds  int64                `json:"total_duration_seconds"`
+	TotalCaloriesBurned   int64                `json:"total_calories_burned"`
+	AverageSessionSeconds float64              `json:"average_session_seconds"`
+	TotalTonnage          float64              `json:"total_tonnage"`
+	Exercises             []ExerciseTonnage    `json:"exercises"`
+	MuscleGroups          []MuscleGroupTonnage `json:"muscle_groups"`
+	Buckets               []StatsBucket        `json:"buckets"`
+}
+
+// ExerciseTonnage sums sets × reps × weight of one exercise. Free-text
+// entries without a catalog exercise are grouped by name.
+type ExerciseTonnage struct {
+	ExerciseID   *int    `json:"exercise_id"`
+	ExerciseName string  `json:"exercise_name"`
+	Sets         int64   `json:"sets"`
+	Reps         int64   `json:"reps"`
+	Tonnage      float64 `json:"tonnage"`
+}
+
+// MuscleGroupTonnage credits the full tonnage of an exercise to each of its
+// primary muscle groups.
+type MuscleGroupTonnage struct {
+	MuscleGroup string  `json:"muscle_group"`
+	Tonnage     float64 `json:"tonnage"`
+}
+
+type StatsBucket struct {
+	Start           time.Time `json:"start"`
+	Sessions        int       `json:"sessions"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	CaloriesBurned  int64     `json:"calories_burned"`
+	Tonnage         float64   `json:"tonnage"`
+}
+
+type StatsStore interface {
+	GetStats(filter StatsFilter) (*Stats, error)
+	GetDailyLoads(userID int, from, to time.Time) ([]trainingload.Day, error)
+}
+
+type PostgresStatsStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStatsStore(db *sql.DB) *PostgresStatsStore {
+	return &PostgresStatsStore{db: db}
+}
+
+func ValidStatsBucket(bucket string) bool {
+	return bucket == StatsBucketWeek || bucket == StatsBucketMonth
+}
+
+// GetStats aggregates in the database so the cost does not grow with the
+// number of rows sent to the application. The queries share a read-only
+// snapshot so their totals agree with each other.
+func (pg *PostgresStatsStore) GetStats(filter StatsFilter) (*Stats, error) {
+	tx, err := pg.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stats := &Stats{From: filter.From, To: filter.To}
+
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(duration_seconds), 0), COALESCE(SUM(calories_burned), 0), COALESCE(AVG(duration_seconds), 0)
+		FROM workouts
+		WHERE user_id = $1 AND deleted_at IS NULL AND created_at >= $2 AND created_at < $3
+	`
+
+	err = tx.QueryRow(query, filter.UserID, filter.From, filter.To).Scan(
+		&stats.TotalSessions,
+		&stats.TotalDurationSeconds,
+		&stats.TotalCaloriesBurned,
+		&stats.AverageSessionSeconds,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Exercises, err = exerciseTonnage(tx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, exercise := range stats.Exercises {
+		stats.TotalTonnage += exercise.Tonnage
+	}
+
+	stats.MuscleGroups, err = muscleGroupTonnage(tx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Buckets, err = statsBuckets(tx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, tx.Commit()
+}
+
+func exerciseTonnage(tx *sql.Tx, filter StatsFilter) ([]ExerciseTonnage, error) {
+	query := `
+		SELECT we.exercise_id, COALESCE(e.name, we.exercise_name), SUM(we.sets), SUM(we.sets * we.reps),
+			SUM(we.sets * we.reps * we.weight)
+		FROM workouts w
+		INNER JOIN workout_entries we ON we.workout_id = w.id
+		LEFT JOIN exercises e ON e.id = we.exercise_id
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.created_at >= $2 AND w.created_at < $3
+			AND we.reps IS NOT NULL AND we.weight IS NOT NULL
+		GROUP BY we.exercise_id, COALESCE(e.name, we.exercise_name)
+		ORDER BY 5 DESC, 2
+	`
+
+	rows, err := tx.Query(query, filter.UserID, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []ExerciseTonnage{}
+	for rows.Next() {
+		var exercise ExerciseTonnage
+		err := rows.Scan(&exercise.ExerciseID, &exercise.ExerciseName, &exercise.Sets, &exercise.Reps, &exercise.Tonnage)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, exercise)
+	}
+
+	return exercises, rows.Err()
+}
+
+func muscleGroupTonnage(tx *sql.Tx, filter StatsFilter) ([]MuscleGroupTonnage, error) {
+	query := `
+		SELECT muscle_group, SUM(we.sets * we.reps * we.weight)
+		FROM workouts w
+		INNER JOIN workout_entries we ON we.workout_id = w.id
+		INNER JOIN exercises e ON e.id = we.exercise_id
+		CROSS JOIN LATERAL unnest(e.primary_muscle_groups) AS muscle_group
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.created_at >= $2 AND w.created_at < $3
+			AND we.reps IS NOT NULL AND we.weight IS NOT NULL
+		GROUP BY muscle_group
+		ORDER BY 2 DESC, 1
+	`
+
+	rows, err := tx.Query(query, filter.UserID, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []MuscleGroupTonnage{}
+	for rows.Next() {
+		var group MuscleGroupTonnage
+		if err := rows.Scan(&group.MuscleGroup, &group.Tonnage); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+// statsBuckets groups workouts by the UTC week (starting Monday) or month
+// they were created in. Empty buckets are left out.
+func statsBuckets(tx *sql.Tx, filter StatsFilter) ([]StatsBucket, error) {
+	query := `
+		SELECT date_trunc($4, w.created_at AT TIME ZONE 'UTC') AS bucket, COUNT(*),
+			COALESCE(SUM(w.duration_seconds), 0), COALESCE(SUM(w.calories_burned), 0), COALESCE(SUM(t.tonnage), 0)
+		FROM workouts w
+		LEFT JOIN LATERAL (
+			SELECT SUM(we.sets * we.reps * we.weight) AS tonnage
+			FROM workout_entries we
+			WHERE we.workout_id = w.id AND we.reps IS NOT NULL AND we.weight IS NOT NULL
+		) t ON TRUE
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.created_at >= $2 AND w.created_at < $3
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+
+	rows, err := tx.Query(query, filter.UserID, filter.From, filter.To, filter.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []StatsBucket{}
+	for rows.Next() {
+		var bucket StatsBucket
+		err := rows.Scan(&bucket.Start, &bucket.Sessions, &bucket.DurationSeconds, &bucket.CaloriesBurned, &bucket.Tonnage)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+// GetDailyLoads sums the session load of the user's workouts per UTC day in
+// [from, to). Workouts without a session RPE fall back to the mean entry RPE
+// weighted by sets; workouts with neither carry no load.
+func (pg *PostgresStatsStore) GetDailyLoads(userID int, from, to time.Time) ([]trainingload.Day, error) {
+	query := `
+		SELECT (w.created_at AT TIME ZONE 'UTC')::date AS day,
+			SUM(w.duration_seconds / 60.0 * COALESCE(w.session_rpe, e.rpe))
+		FROM workouts w
+		LEFT JOIN LATERAL (
+			SELECT SUM(we.rpe * we.sets) / SUM(we.sets) AS rpe
+			FROM workout_entries we
+			WHERE we.workout_id = w.id AND we.rpe IS NOT NULL
+		) e ON TRUE
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.created_at >= $2 AND w.created_at < $3
+			AND COALESCE(w.session_rpe, e.rpe) IS NOT NULL
+		GROUP BY day
+		ORDER BY day
+	`
+
+	rows, err := pg.db.Query(query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []trainingload.Day{}
+	for rows.Next() {
+		var day trainingload.Day
+		if err := rows.Scan(&day.Date, &day.Load); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, exercises CASCADE")
+	require.NoError(t, err)
+
+	statsStore := NewPostgresStatsStore(db)
+	workoutStore := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "counter")
+
+	bench := &Exercise{Name: "Bench Press", PrimaryMuscleGroups: []string{"chest", "triceps"}, MovementType: MovementTypeReps}
+	require.NoError(t, NewPostgresExerciseStore(db).CreateExercise(bench))
+
+	workouts := []*Workout{
+		{
+			UserID:          user.ID,
+			Title:           "Push",
+			DurationSeconds: 3600,
+			CaloriesBurned:  400,
+			Entries: []WorkoutEntry{
+				{ExerciseID: IntPtr(bench.ID), Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 0},
+				{ExerciseName: "Sled push", Sets: 2, Reps: IntPtr(10), Weight: FloatPtr(50), OrderIndex: 1},
+				{ExerciseName: "Plank", Sets: 2, DurationSeconds: IntPtr(60), OrderIndex: 2},
+			},
+		},
+		{
+			UserID:          user.ID,
+			Title:           "Push again",
+			DurationSeconds: 1800,
+			CaloriesBurned:  200,
+			Entries: []WorkoutEntry{
+				{ExerciseID: IntPtr(bench.ID), Sets: 1, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 0},
+			},
+		},
+		{
+			UserID:          user.ID,
+			Title:           "Trashed",
+			DurationSeconds: 600,
+			Entries: []WorkoutEntry{
+				{ExerciseID: IntPtr(bench.ID), Sets: 10, Reps: IntPtr(10), Weight: FloatPtr(100), OrderIndex: 0},
+			},
+		},
+	}
+	for _, workout := range workouts {
+		_, err := workoutStore.CreateWorkout(workout)
+		require.NoError(t, err)
+	}
+	require.NoError(t, workoutStore.DeleteWorkout(int64(workouts[2].ID), workouts[2].Version))
+
+	now := time.Now()
+	stats, err := statsStore.GetStats(StatsFilter{UserID: user.ID, From: now.Add(-time.Hour), To: now.Add(time.Hour), Bucket: StatsBucketMonth})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, stats.TotalSessions)
+	assert.Equal(t, int64(5400), stats.TotalDurationSeconds)
+	assert.Equal(t, int64(600), stats.TotalCaloriesBurned)
+	assert.Equal(t, 2700.0, stats.AverageSessionSeconds)
+	assert.Equal(t, 3000.0, stats.TotalTonnage)
+	assert.Equal(t, []ExerciseTonnage{
+		{ExerciseID: IntPtr(bench.ID), ExerciseName: "Bench Press", Sets: 4, Reps: 20, Tonnage: 2000},
+		{ExerciseName: "Sled push", Sets: 2, Reps: 20, Tonnage: 1000},
+	}, stats.Exercises)
+	assert.Equal(t, []MuscleGroupTonnage{
+		{MuscleGroup: "chest", Tonnage: 2000},
+		{MuscleGroup: "triceps", Tonnage: 2000},
+	}, stats.MuscleGroups)
+	// the hour around now can straddle the start of a month
+	sessions, tonnage := 0, 0.0
+	for _, bucket := range stats.Buckets {
+		sessions += bucket.Sessions
+		tonnage += bucket.Tonnage
+	}
+	assert.Equal(t, 2, sessions)
+	assert.Equal(t, 3000.0, tonnage)
+
+	empty, err := statsStore.GetStats(StatsFilter{UserID: user.ID, From: now.Add(time.Hour), To: now.Add(2 * time.Hour), Bucket: StatsBucketWeek})
+	require.NoError(t, err)
+	assert.Zero(t, empty.TotalSessions)
+	assert.Empty(t, empty.Exercises)
+	assert.Empty(t, empty.Buckets)
+}
+
+func TestGetDailyLoads(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	statsStore := NewPostgresStatsStore(db)
+	workoutStore := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "loaded")
+
+	workouts := []*Workout{
+		{UserID: user.ID, Title: "Session RPE", DurationSeconds: 3600, SessionRPE: FloatPtr(7)},
+		{
+			UserID:          user.ID,
+			Title:           "Entry RPE",
+			DurationSeconds: 1800,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), RPE: FloatPtr(8), OrderIndex: 0},
+				{ExerciseName: "Lunge", Sets: 1, Reps: IntPtr(10), RPE: FloatPtr(4), OrderIndex: 1},
+			},
+		},
+		{UserID: user.ID, Title: "No RPE", DurationSeconds: 3600},
+	}
+	for _, workout := range workouts {
+		_, err := workoutStore.CreateWorkout(workout)
+		require.NoError(t, err)
+	}
+
+	now := time.Now()
+	days, err := statsStore.GetDailyLoads(user.ID, now.Add(-24*time.Hour), now.Add(24*time.Hour))
+	require.NoError(t, err)
+
+	// 60 min x 7 plus 30 min x the set-weighted RPE of 7
+	total := 0.0
+	for _, day := range days {
+		total += day.Load
+	}
+	assert.Equal(t, 420.0+210.0, total)
+}
+
+// BenchmarkGetStats runs the stats queries against 100,000 hourly workouts of
+// ten entries each, one million entries in all.
+func BenchmarkGetStats(b *testing.B) {
+	db := setupTestDB(b)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, exercises CASCADE")
+	require.NoError(b, err)
+
+	user := createTestUser(b, db, "heavy")
+	exerciseStore := NewPostgresExerciseStore(db)
+	squat := &Exercise{Name: "Squat", PrimaryMuscleGroups: []string{"quads", "glutes"}, MovementType: MovementTypeReps}
+	require.NoError(b, exerciseStore.CreateExercise(squat))
+	bench := &Exercise{Name: "Bench Press", PrimaryMuscleGroups: []string{"chest"}, MovementType: MovementTypeReps}
+	require.NoError(b, exerciseStore.CreateExercise(bench))
+
+	now := time.Now().UTC()
+	_, err = db.Exec(`
+		INSERT INTO workouts (user_id, title, duration_seconds, calories_burned, created_at)
+		SELECT $1, 'Workout ' || g, 1800 + g % 3600, 300 + g % 400, $2::timestamptz - g * INTERVAL '1 hour'
+		FROM generate_series(1, 100000) g
+	`, user.ID, now)
+	require.NoError(b, err)
+
+	_, err = db.Exec(`
+		INSERT INTO workout_entries (workout_id, exercise_id, exercise_name, sets, reps, weight, order_index)
+		SELECT w.id, (ARRAY[$2, $3]::BIGINT[])[i % 2 + 1], 'seeded', 3, 5 + i % 5, 60 + i * 5, i
+		FROM workouts w
+		CROSS JOIN generate_series(0, 9) i
+		WHERE w.user_id = $1
+	`, user.ID, squat.ID, bench.ID)
+	require.NoError(b, err)
+
+	_, err = db.Exec("ANALYZE workouts, workout_entries")
+	require.NoError(b, err)
+
+	statsStore := NewPostgresStatsStore(db)
+	ranges := []struct {
+		name string
+		days int
+	}{
+		{name: "90 days", days: 90},
+		{name: "1 year", days: 365},
+		{name: "all time", days: 365 * 12},
+	}
+
+	for _, r := range ranges {
+		b.Run(r.name, func(b *testing.B) {
+			filter := StatsFilter{UserID: user.ID, From: now.AddDate(0, 0, -r.days), To: now, Bucket: StatsBucketWeek}
+			for b.Loop() {
+				_, err := statsStore.GetStats(filter)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"go-server/internal/validator"
+	"time"
+)
+
+// ErrTemplateInUse is returned when deleting a template a program session
+// still points at.
+var ErrTemplateInUse = fmt.Errorf("template is used by a program: %w", ErrConflict)
+
+type WorkoutTemplate struct {
+	ID          int             `json:"id"`
+	UserID      int             `json:"user_id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	ShareCode   *string         `json:"share_code"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Entries     []TemplateEntry `json:"entries"`
+}
+
+// TemplateEntry is a planned exercise. Entries are kept in the order they
+// are given, so there is no order_index in the API.
+type TemplateEntry struct {
+	ID                    int      `json:"id"`
+	ExerciseID            *int     `json:"exercise_id"`
+	ExerciseName          string   `json:"exercise_name"`
+	TargetSets            int      `json:"target_sets"`
+	TargetReps            *int     `json:"target_reps"`
+	TargetDurationSeconds *int     `json:"target_duration_seconds"`
+	TargetWeight          *float64 `json:"target_weight"`
+	RestSeconds           *int     `json:"rest_seconds"`
+	Notes                 string   `json:"notes"`
+}
+
+const (
+	maxRestSeconds = 60 * 60
+	// assumedSetSeconds is how long a set with a rep target is expected to
+	// take when estimating a workout's duration from its template.
+	assumedSetSeconds        = 45
+	minStartedWorkoutSeconds = 60
+)
+
+func ValidateTemplate(v *validator.Validator, template *WorkoutTemplate) {
+	v.Check(validator.NotBlank(template.Name), "name", "is required")
+	v.Check(validator.MaxChars(template.Name, 255), "name", "cannot exceed 255 characters")
+	v.Check(validator.MaxChars(template.Description, 10000), "description", "cannot exceed 10000 characters")
+	v.Check(len(template.Entries) <= MaxWorkoutEntries, "entries", fmt.Sprintf("cannot contain more than %d entries", MaxWorkoutEntries))
+
+	for i := range template.Entries {
+		ValidateTemplateEntry(v, fmt.Sprintf("entries[%d].", i), &template.Entries[i])
+	}
+}
+
+// ValidateTemplateEntry applies the workout entry rules to the targets.
+func ValidateTemplateEntry(v *validator.Validator, prefix string, entry *TemplateEntry) {
+	if entry.ExerciseID == nil {
+		v.Check(validator.NotBlank(entry.ExerciseName), prefix+"exercise_name", "is required unless exercise_id is set")
+	}
+	v.Check(validator.MaxChars(entry.ExerciseName, 255), prefix+"exercise_name", "cannot exceed 255 characters")
+	v.Check(validator.Between(entry.TargetSets, 1, 100), prefix+"target_sets", "must be between 1 and 100")
+
+	switch {
+	case entry.TargetReps == nil && entry.TargetDurationSeconds == nil:
+		v.AddError(prefix+"target_reps", "either target_reps or target_duration_seconds is required")
+	case entry.TargetReps != nil && entry.TargetDurationSeconds != nil:
+		v.AddError(prefix+"target_reps", "cannot be combined with target_duration_seconds")
+	case entry.TargetReps != nil:
+		v.Check(validator.Between(*entry.TargetReps, 1, 1000), prefix+"target_reps", "must be between 1 and 1000")
+	default:
+		v.Check(validator.Between(*entry.TargetDurationSeconds, 1, maxDurationSeconds), prefix+"target_duration_seconds", "must be between 1 and 86400")
+	}
+
+	if entry.TargetWeight != nil {
+		v.Check(validator.Between(*entry.TargetWeight, 0, maxEntryWeight), prefix+"target_weight", "must be between 0 and 999.99")
+		v.Check(validator.MaxDecimalPlaces(*entry.TargetWeight, 2), prefix+"target_weight", "cannot have more than 2 decimal places")
+	}
+	if entry.RestSeconds != nil {
+		v.Check(validator.Between(*entry.RestSeconds, 0, maxRestSeconds), prefix+"rest_seconds", "must be between 0 and 3600")
+	}
+
+	v.Check(validator.MaxChars(entry.Notes, 1000), prefix+"notes", "cannot exceed 1000 characters")
+}
+
+// NewWorkout builds a private workout for userID with one entry per template
+// entry, set to the targets. The duration is an estimate from the targets
+// and rest times; clients are expected to correct it when the session ends.
+func (t *WorkoutTemplate) NewWorkout(userID int) *Workout {
+	workout := &Workout{
+		UserID:      userID,
+		Title:       t.Name,
+		Description: t.Description,
+		Visibility:  VisibilityPrivate,
+		Entries:     make([]WorkoutEntry, 0, len(t.Entries)),
+	}
+
+	for i, entry := range t.Entries {
+		workout.Entries = append(workout.Entries, WorkoutEntry{
+			ExerciseID:      entry.ExerciseID,
+			ExerciseName:    entry.ExerciseName,
+			Sets:            entry.TargetSets,
+			Reps:            entry.TargetReps,
+			DurationSeconds: entry.TargetDurationSeconds,
+			Weight:          entry.TargetWeight,
+			Notes:           entry.Notes,
+			OrderIndex:      i,
+		})
+
+		setSeconds := assumedSetSeconds
+		if entry.TargetDurationSeconds != nil {
+			setSeconds = *entry.TargetDurationSeconds
+		}
+		workout.DurationSeconds += entry.TargetSets * setSeconds
+		if entry.RestSeconds != nil {
+			workout.DurationSeconds += (entry.TargetSets - 1) * *entry.RestSeconds
+		}
+	}
+
+	workout.DurationSeconds = min(max(workout.DurationSeconds, minStartedWorkoutSeconds), maxDurationSeconds)
+
+	return workout
+}
+
+// TemplateFromWorkout turns a logged workout into a template whose targets
+// are what was actually done.
+func TemplateFromWorkout(workout *Workout) *WorkoutTemplate {
+	template := &WorkoutTemplate{
+		UserID:      workout.UserID,
+		Name:        workout.Title,
+		Description: workout.Description,
+		Entries:     make([]TemplateEntry, 0, len(workout.Entries)),
+	}
+
+	for _, entry := range workout.Entries {
+		template.Entries = append(template.Entries, TemplateEntry{
+			ExerciseID:            entry.ExerciseID,
+			ExerciseName:          entry.ExerciseName,
+			TargetSets:            entry.Sets,
+			TargetReps:            entry.Reps,
+			TargetDurationSeconds: entry.DurationSeconds,
+			TargetWeight:          entry.Weight,
+			Notes:                 entry.Notes,
+		})
+	}
+
+	return template
+}
+
+type TemplateStore interface {
+	CreateTemplate(*WorkoutTemplate) error
+	GetTemplateByID(id int64) (*WorkoutTemplate, error)
+	GetTemplateByShareCode(code string) (*WorkoutTemplate, error)
+	ListTemplates(userID int) ([]*WorkoutTemplate, error)
+	UpdateTemplate(*WorkoutTemplate) error
+	DeleteTemplate(id int64) error
+	SetTemplateShareCode(id int64, code *string) error
+}
+
+type PostgresTemplateStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTemplateStore(db *sql.DB) *PostgresTemplateStore {
+	return &PostgresTemplateStore{db: db}
+}
+
+func (pg *PostgresTemplateStore) CreateTemplate(template *WorkoutTemplate) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO workout_templates (user_id, name, description)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	err = tx.QueryRow(query, template.UserID, template.Name, template.Description).Scan(&template.ID, &template.CreatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	err = insertTemplateEntries(tx, template)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertTemplateEntries(tx *sql.Tx, template *WorkoutTemplate) error {
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+
+		// templates link to the catalog the same way logged entries do
+		resolved := WorkoutEntry{ExerciseID: entry.ExerciseID, ExerciseName: entry.ExerciseName}
+		err := resolveEntryExercise(tx, &resolved)
+		if err != nil {
+			return err
+		}
+		entry.ExerciseID, entry.ExerciseName = resolved.ExerciseID, resolved.ExerciseName
+
+		query := `
+			INSERT INTO workout_template_entries (template_id, exercise_id, exercise_name, target_sets, target_reps, target_duration_seconds, target_weight, rest_seconds, notes, order_index)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id
+		`
+
+		err = tx.QueryRow(query, template.ID, entry.ExerciseID, entry.ExerciseName, entry.TargetSets, entry.TargetReps, entry.TargetDurationSeconds, entry.TargetWeight, entry.RestSeconds, entry.Notes, i).Scan(&entry.ID)
+		if err != nil {
+			return translateError(err)
+		}
+	}
+
+	return nil
+}
+
+func (pg *PostgresTemplateStore) GetTemplateByID(id int64) (*WorkoutTemplate, error) {
+	return pg.getTemplate(`WHERE id = $1`, id)
+}
+
+func (pg *PostgresTemplateStore) GetTemplateByShareCode(code string) (*WorkoutTemplate, error) {
+	return pg.getTemplate(`WHERE share_code = $1`, code)
+}
+
+func (pg *PostgresTemplateStore) getTemplate(where string, arg any) (*WorkoutTemplate, error) {
+	template := &WorkoutTemplate{}
+
+	query := `
+		SELECT id, user_id, name, description, share_code, created_at
+		FROM workout_templates
+	` + where
+
+	err := pg.db.QueryRow(query, arg).Scan(
+		&template.ID,
+		&template.UserID,
+		&template.Name,
+		&template.Description,
+		&template.ShareCode,
+		&template.CreatedAt,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	entries, err := pg.templateEntries([]int64{int64(template.ID)})
+	if err != nil {
+		return nil, err
+	}
+	template.Entries = entries[template.ID]
+	if template.Entries == nil {
+		template.Entries = []TemplateEntry{}
+	}
+
+	return template, nil
+}
+
+func (pg *PostgresTemplateStore) ListTemplates(userID int) ([]*WorkoutTemplate, error) {
+	query := `
+		SELECT id, user_id, name, description, share_code, created_at
+		FROM workout_templates
+		WHERE user_id = $1
+		ORDER BY name, id
+	`
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*WorkoutTemplate{}
+	ids := []int64{}
+	for rows.Next() {
+		template := &WorkoutTemplate{Entries: []TemplateEntry{}}
+		err := rows.Scan(
+			&template.ID,
+			&template.UserID,
+			&template.Name,
+			&template.Description,
+			&template.ShareCode,
+			&template.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+		ids = append(ids, int64(template.ID))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := pg.templateEntries(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range templates {
+		if templateEntries, ok := entries[template.ID]; ok {
+			template.Entries = templateEntries
+		}
+	}
+
+	return templates, nil
+}
+
+func (pg *PostgresTemplateStore) templateEntries(templateIDs []int64) (map[int][]TemplateEntry, error) {
+	entries := map[int][]TemplateEntry{}
+	if len(templateIDs) == 0 {
+		return entries, nil
+	}
+
+	query := `
+		SELECT id, template_id, exercise_id, exercise_name, target_sets, target_reps, target_duration_seconds, target_weight, rest_seconds, notes
+		FROM workout_template_entries
+		WHERE template_id = ANY($1)
+		ORDER BY template_id, order_index
+	`
+
+	rows, err := pg.db.Query(query, templateIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry TemplateEntry
+		var templateID int
+		err := rows.Scan(
+			&entry.ID,
+			&templateID,
+			&entry.ExerciseID,
+			&entry.ExerciseName,
+			&entry.TargetSets,
+			&entry.TargetReps,
+			&entry.TargetDurationSeconds,
+			&entry.TargetWeight,
+			&entry.RestSeconds,
+			&entry.Notes,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries[templateID] = append(entries[templateID], entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// UpdateTemplate replaces the template's fields and entries. Nothing refers
+// to template entries, so unlike workout entries they are simply rewritten.
+func (pg *PostgresTemplateStore) UpdateTemplate(template *WorkoutTemplate) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE workout_templates
+		SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+		RETURNING user_id, share_code, created_at
+	`
+
+	err = tx.QueryRow(query, template.Name, template.Description, template.ID).Scan(&template.UserID, &template.ShareCode, &template.CreatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM workout_template_entries WHERE template_id = $1`, template.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertTemplateEntries(tx, template)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresTemplateStore) DeleteTemplate(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM workout_templates WHERE id = $1`, id)
+	if isForeignKeyViolation(err, "program_sessions_template_id_fkey") {
+		return ErrTemplateInUse
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	return requireRowsAffected(result)
+}
+
+// SetTemplateShareCode publishes the template under code, or unpublishes it
+// when code is nil. Setting a new code invalidates links using the old one.
+func (pg *PostgresTemplateStore) SetTemplateShareCode(id int64, code *string) error {
+	query := `
+		UPDATE workout_templates
+		SET share_code = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	result, err := pg.db.Exec(query, code, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return requireRowsAffected(result)
+}
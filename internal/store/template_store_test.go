@@ -0,0 +1,117 @@
+package store
+
+import (
+	"go-server/internal/validator"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	template := &WorkoutTemplate{
+		Name: "Push day",
+		Entries: []TemplateEntry{
+			{ExerciseName: "Bench Press", TargetSets: 3, TargetReps: IntPtr(8), TargetWeight: FloatPtr(80), RestSeconds: IntPtr(120)},
+			{ExerciseName: "Plank", TargetSets: 0, TargetReps: IntPtr(1), TargetDurationSeconds: IntPtr(60), RestSeconds: IntPtr(-1)},
+		},
+	}
+
+	v := validator.New()
+	ValidateTemplate(v, template)
+
+	assert.Equal(t, map[string]string{
+		"entries[1].target_sets":  "must be between 1 and 100",
+		"entries[1].target_reps":  "cannot be combined with target_duration_seconds",
+		"entries[1].rest_seconds": "must be between 0 and 3600",
+	}, v.Errors)
+}
+
+func TestTemplateNewWorkout(t *testing.T) {
+	template := &WorkoutTemplate{
+		Name:        "Push day",
+		Description: "heavy",
+		Entries: []TemplateEntry{
+			{ExerciseID: IntPtr(4), ExerciseName: "Bench Press", TargetSets: 3, TargetReps: IntPtr(8), TargetWeight: FloatPtr(80), RestSeconds: IntPtr(120)},
+			{ExerciseName: "Plank", TargetSets: 2, TargetDurationSeconds: IntPtr(60)},
+		},
+	}
+
+	workout := template.NewWorkout(7)
+
+	assert.Equal(t, 7, workout.UserID)
+	assert.Equal(t, "Push day", workout.Title)
+	assert.Equal(t, VisibilityPrivate, workout.Visibility)
+	// 3 sets * 45s + 2 rests * 120s + 2 sets * 60s
+	assert.Equal(t, 135+240+120, workout.DurationSeconds)
+	require.Len(t, workout.Entries, 2)
+	assert.Equal(t, WorkoutEntry{ExerciseID: IntPtr(4), ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(80)}, workout.Entries[0])
+	assert.Equal(t, 1, workout.Entries[1].OrderIndex)
+
+	v := validator.New()
+	ValidateWorkout(v, workout)
+	assert.True(t, v.Valid(), v.Errors)
+
+	empty := (&WorkoutTemplate{Name: "Rest day"}).NewWorkout(7)
+	assert.Equal(t, minStartedWorkoutSeconds, empty.DurationSeconds)
+}
+
+func TestTemplateFromWorkout(t *testing.T) {
+	workout := &Workout{
+		UserID: 3,
+		Title:  "Leg day",
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(140), OrderIndex: 0},
+		},
+	}
+
+	template := TemplateFromWorkout(workout)
+
+	assert.Equal(t, 3, template.UserID)
+	assert.Equal(t, "Leg day", template.Name)
+	assert.Equal(t, []TemplateEntry{{ExerciseName: "Squat", TargetSets: 5, TargetReps: IntPtr(5), TargetWeight: FloatPtr(140)}}, template.Entries)
+}
+
+func TestTemplateStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	store := NewPostgresTemplateStore(db)
+	user := createTestUser(t, db, "planner")
+
+	template := &WorkoutTemplate{
+		UserID: user.ID,
+		Name:   "Push day",
+		Entries: []TemplateEntry{
+			{ExerciseName: "bench press", TargetSets: 3, TargetReps: IntPtr(8)},
+			{ExerciseName: "Plank", TargetSets: 3, TargetDurationSeconds: IntPtr(60)},
+		},
+	}
+	require.NoError(t, store.CreateTemplate(template))
+
+	template.Entries = template.Entries[1:]
+	require.NoError(t, store.UpdateTemplate(template))
+
+	code := "share-me"
+	require.NoError(t, store.SetTemplateShareCode(int64(template.ID), &code))
+
+	shared, err := store.GetTemplateByShareCode(code)
+	require.NoError(t, err)
+	assert.Equal(t, template.ID, shared.ID)
+	require.Len(t, shared.Entries, 1)
+	assert.Equal(t, "Plank", shared.Entries[0].ExerciseName)
+
+	require.NoError(t, store.SetTemplateShareCode(int64(template.ID), nil))
+	_, err = store.GetTemplateByShareCode(code)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	templates, err := store.ListTemplates(user.ID)
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+
+	require.NoError(t, store.DeleteTemplate(int64(template.ID)))
+	assert.ErrorIs(t, store.DeleteTemplate(int64(template.ID)), ErrNotFound)
+}
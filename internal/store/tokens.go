@@ -1,15 +1,43 @@
 package store
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"errors"
 	"go-server/internal/tokens"
 	"time"
 )
 
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrTokenReused  = errors.New("refresh token reused")
+)
+
 type TokenStore interface {
 	Insert(token *tokens.Token) error
 	CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error)
+	CreateTokenPair(userID int, accessTTL, refreshTTL time.Duration, client tokens.Client) (*tokens.TokenPair, error)
+	RotateRefreshToken(plainText string, accessTTL, refreshTTL time.Duration, client tokens.Client) (*tokens.TokenPair, error)
 	DeleteAllTokensForUser(userID int, scope string) error
+	DeleteToken(plainText string) error
+	TouchToken(plainText string) error
+	ListSessions(userID int, currentToken string) ([]*Session, error)
+}
+
+// Session groups the tokens of one sign-in: the access and refresh tokens
+// sharing a family, or a single token issued without one.
+type Session struct {
+	ID         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	Current    bool       `json:"current"`
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
 }
 
 type PostgresTokenStore struct {
@@ -33,16 +61,118 @@ func (t *PostgresTokenStore) CreateNewToken(userID int, ttl time.Duration, scope
 }
 
 func (t *PostgresTokenStore) Insert(token *tokens.Token) error {
+	return insertToken(t.db, token)
+}
+
+func insertToken(exec execer, token *tokens.Token) error {
 	query := `
-		INSERT INTO tokens (hash, user_id, expiry, scope) 
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO tokens (hash, user_id, expiry, scope, family_id, user_agent, ip_address) 
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''))
 	`
 
-	_, err := t.db.Exec(query, token.Hash, token.UserId, token.Expiry, token.Scope)
+	_, err := exec.Exec(query, token.Hash, token.UserId, token.Expiry, token.Scope, token.FamilyID, token.Client.UserAgent, token.Client.IPAddress)
 
 	return err
 }
 
+func (t *PostgresTokenStore) CreateTokenPair(userID int, accessTTL, refreshTTL time.Duration, client tokens.Client) (*tokens.TokenPair, error) {
+	pair, err := tokens.GenerateTokenPair(userID, accessTTL, refreshTTL, "", client)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = insertToken(tx, pair.Access)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertToken(tx, pair.Refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, tx.Commit()
+}
+
+// RotateRefreshToken exchanges a refresh token for a new pair in the same
+// family. Refresh tokens are single use: presenting one that was already
+// exchanged revokes the whole family, since either the client or an attacker
+// is holding a stolen copy.
+func (t *PostgresTokenStore) RotateRefreshToken(plainText string, accessTTL, refreshTTL time.Duration, client tokens.Client) (*tokens.TokenPair, error) {
+	hash := sha256.Sum256([]byte(plainText))
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT user_id, COALESCE(family_id, ''), used_at, expiry
+		FROM tokens
+		WHERE hash = $1 AND scope = $2
+		FOR UPDATE
+	`
+
+	var userID int
+	var familyID string
+	var usedAt sql.NullTime
+	var expiry time.Time
+	err = tx.QueryRow(query, hash[:], tokens.ScopeRefresh).Scan(&userID, &familyID, &usedAt, &expiry)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		_, err = tx.Exec(`DELETE FROM tokens WHERE family_id = $1 OR hash = $2`, familyID, hash[:])
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ErrTokenReused
+	}
+
+	if expiry.Before(time.Now()) {
+		return nil, ErrInvalidToken
+	}
+
+	_, err = tx.Exec(`UPDATE tokens SET used_at = CURRENT_TIMESTAMP WHERE hash = $1`, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := tokens.GenerateTokenPair(userID, accessTTL, refreshTTL, familyID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertToken(tx, pair.Access)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertToken(tx, pair.Refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, tx.Commit()
+}
+
 func (t *PostgresTokenStore) DeleteAllTokensForUser(userID int, scope string) error {
 	query := `
 		DELETE FROM tokens WHERE user_id = $1 AND scope = $2
@@ -63,3 +193,86 @@ func (t *PostgresTokenStore) DeleteAllTokensForUser(userID int, scope string) er
 
 	return nil
 }
+
+// DeleteToken revokes the session the token belongs to, so signing out also
+// invalidates the refresh token issued alongside it.
+func (t *PostgresTokenStore) DeleteToken(plainText string) error {
+	hash := sha256.Sum256([]byte(plainText))
+	query := `
+		DELETE FROM tokens
+		WHERE hash = $1
+		OR family_id = (SELECT family_id FROM tokens WHERE hash = $1)
+	`
+
+	result, err := t.db.Exec(query, hash[:])
+	if err != nil {
+		return err
+	}
+
+	return requireRowsAffected(result)
+}
+
+// TouchToken records that the token was just used. Writes are throttled to
+// one per minute so authenticated requests don't each cost an UPDATE.
+func (t *PostgresTokenStore) TouchToken(plainText string) error {
+	hash := sha256.Sum256([]byte(plainText))
+	query := `
+		UPDATE tokens SET last_used_at = CURRENT_TIMESTAMP
+		WHERE hash = $1
+		AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+	`
+
+	_, err := t.db.Exec(query, hash[:])
+	return err
+}
+
+func (t *PostgresTokenStore) ListSessions(userID int, currentToken string) ([]*Session, error) {
+	hash := sha256.Sum256([]byte(currentToken))
+	query := `
+		SELECT
+			COALESCE(family_id, encode(MIN(hash), 'hex')) AS session_id,
+			MIN(created_at),
+			MAX(expiry),
+			MAX(last_used_at),
+			COALESCE((array_agg(user_agent ORDER BY created_at DESC))[1], ''),
+			COALESCE((array_agg(ip_address ORDER BY created_at DESC))[1], ''),
+			bool_or(hash = $2)
+		FROM tokens
+		WHERE user_id = $1
+		AND scope IN ($3, $4)
+		AND expiry > CURRENT_TIMESTAMP
+		AND used_at IS NULL
+		GROUP BY family_id, CASE WHEN family_id IS NULL THEN hash END
+		ORDER BY MAX(COALESCE(last_used_at, created_at)) DESC
+	`
+
+	rows, err := t.db.Query(query, userID, hash[:], tokens.ScopeAuth, tokens.ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session := &Session{}
+		err := rows.Scan(
+			&session.ID,
+			&session.CreatedAt,
+			&session.ExpiresAt,
+			&session.LastUsedAt,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.Current,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"go-server/internal/tokens"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	user := createTestUser(t, db, "refresher")
+	tokenStore := NewPostgresTokenStore(db)
+	userStore := NewPostgresUserStore(db)
+
+	client := tokens.Client{UserAgent: "test-agent", IPAddress: "127.0.0.1"}
+
+	pair, err := tokenStore.CreateTokenPair(user.ID, time.Hour, 24*time.Hour, client)
+	require.NoError(t, err)
+	assert.Equal(t, pair.Access.FamilyID, pair.Refresh.FamilyID)
+
+	authUser, err := userStore.GetUserToken(tokens.ScopeAuth, pair.Access.PlainText)
+	require.NoError(t, err)
+	require.NotNil(t, authUser)
+	assert.Equal(t, user.ID, authUser.ID)
+
+	_, err = tokenStore.RotateRefreshToken(pair.Access.PlainText, time.Hour, 24*time.Hour, client)
+	assert.ErrorIs(t, err, ErrInvalidToken, "access tokens cannot be used to refresh")
+
+	rotated, err := tokenStore.RotateRefreshToken(pair.Refresh.PlainText, time.Hour, 24*time.Hour, client)
+	require.NoError(t, err)
+	assert.NotEqual(t, pair.Refresh.PlainText, rotated.Refresh.PlainText)
+	assert.Equal(t, pair.Refresh.FamilyID, rotated.Refresh.FamilyID)
+
+	_, err = tokenStore.RotateRefreshToken(pair.Refresh.PlainText, time.Hour, 24*time.Hour, client)
+	assert.ErrorIs(t, err, ErrTokenReused)
+
+	_, err = tokenStore.RotateRefreshToken(rotated.Refresh.PlainText, time.Hour, 24*time.Hour, client)
+	assert.ErrorIs(t, err, ErrInvalidToken, "reuse revokes the whole family")
+
+	_, err = userStore.GetUserToken(tokens.ScopeAuth, rotated.Access.PlainText)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	user := createTestUser(t, db, "sessions")
+	tokenStore := NewPostgresTokenStore(db)
+
+	phone, err := tokenStore.CreateTokenPair(user.ID, time.Hour, 24*time.Hour, tokens.Client{UserAgent: "phone"})
+	require.NoError(t, err)
+	laptop, err := tokenStore.CreateTokenPair(user.ID, time.Hour, 24*time.Hour, tokens.Client{UserAgent: "laptop"})
+	require.NoError(t, err)
+
+	require.NoError(t, tokenStore.TouchToken(phone.Access.PlainText))
+
+	sessions, err := tokenStore.ListSessions(user.ID, phone.Access.PlainText)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	for _, session := range sessions {
+		assert.Equal(t, session.UserAgent == "phone", session.Current)
+		if session.Current {
+			assert.NotNil(t, session.LastUsedAt)
+		}
+	}
+
+	require.NoError(t, tokenStore.DeleteToken(laptop.Access.PlainText))
+
+	sessions, err = tokenStore.ListSessions(user.ID, phone.Access.PlainText)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "phone", sessions[0].UserAgent)
+
+	_, err = tokenStore.RotateRefreshToken(laptop.Refresh.PlainText, time.Hour, 24*time.Hour, tokens.Client{})
+	assert.ErrorIs(t, err, ErrInvalidToken, "signing out revokes the session refresh token")
+}
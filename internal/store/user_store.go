@@ -4,18 +4,35 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
+	"go-server/internal/tokens"
+	"go-server/internal/validator"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrDuplicateUsername = fmt.Errorf("username is already taken: %w", ErrConflict)
+	ErrDuplicateEmail    = fmt.Errorf("email is already registered: %w", ErrConflict)
+)
+
+var bcryptCost = 12
+
+// SetBcryptCost sets the cost used by password.Set for new hashes. Existing
+// hashes keep their own cost, so it can be raised without a migration.
+func SetBcryptCost(cost int) {
+	bcryptCost = cost
+}
+
 type password struct {
 	plainText *string
 	hash      []byte
 }
 
 func (p *password) Set(plainTextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plainTextPassword), 12)
+	hash, err := bcrypt.GenerateFromPassword([]byte(plainTextPassword), bcryptCost)
 	if err != nil {
 		return err
 	}
@@ -50,14 +67,37 @@ type User struct {
 	Email        string    `json:"email"`
 	PasswordHash password  `json:"-"`
 	Bio          string    `json:"bio"`
+	Activated    bool      `json:"activated"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+func ValidateUsername(v *validator.Validator, username string) {
+	v.Check(validator.NotBlank(username), "username", "is required")
+	v.Check(validator.MaxChars(username, 50), "username", "cannot exceed 50 characters")
+}
+
+func ValidateEmail(v *validator.Validator, email string) {
+	v.Check(email != "", "email", "is required")
+	v.Check(len(email) <= 50, "email", "cannot exceed 50 characters")
+	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
+}
+
+// ValidatePasswordPlaintext rejects passwords bcrypt would refuse to hash.
+func ValidatePasswordPlaintext(v *validator.Validator, key, password string) {
+	v.Check(password != "", key, "is required")
+	v.Check(len(password) <= 72, key, "cannot exceed 72 bytes")
+}
+
 type UserStore interface {
 	CreateUser(user *User) error
 	GetUserByEmail(email string) (*User, error)
+	GetUserByID(id int) (*User, error)
+	GetUserByUsername(username string) (*User, error)
 	UpdateUser(user *User) error
+	UpdatePassword(user *User, keepToken string) error
+	ActivateUser(user *User) error
+	DeleteUser(id int) error
 	GetUserToken(scope, tokenPlainText string) (*User, error)
 }
 
@@ -81,23 +121,41 @@ func (u *PostgresUserStore) CreateUser(user *User) error {
 	query := `
 		INSERT INTO users (username, email, password_hash, bio)
 		values ($1, $2, $3, $4)
-		RETURNING ID;
+		RETURNING id, activated;
 	`
 
-	err := u.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID)
+	err := u.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.Activated)
 	if err != nil {
-		return err
+		return duplicateUserError(err)
 	}
 
 	return nil
 }
 
+// duplicateUserError maps unique violations on users to sentinel errors so
+// handlers can answer with a conflict instead of a server error.
+func duplicateUserError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation {
+		return translateError(err)
+	}
+
+	switch pgErr.ConstraintName {
+	case "users_username_key":
+		return ErrDuplicateUsername
+	case "users_email_key":
+		return ErrDuplicateEmail
+	default:
+		return translateError(err)
+	}
+}
+
 func (u *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
 	var user = &User{
 		PasswordHash: password{},
 	}
 	query := `
-		SELECT id, username, email, password_hash, bio FROM users WHERE email = $1
+		SELECT id, username, email, password_hash, bio, activated FROM users WHERE email = $1
 	`
 
 	err := u.db.QueryRow(query, email).Scan(
@@ -106,47 +164,200 @@ func (u *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
 		&user.Email,
 		&user.PasswordHash.hash,
 		&user.Bio,
+		&user.Activated,
 	)
-	if err == sql.ErrNoRows {
-		return nil, nil
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return user, nil
+}
+
+func (u *PostgresUserStore) GetUserByID(id int) (*User, error) {
+	return u.getUser(`WHERE id = $1`, id)
+}
+
+func (u *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
+	return u.getUser(`WHERE username = $1`, username)
+}
+
+func (u *PostgresUserStore) getUser(where string, args ...any) (*User, error) {
+	var user = &User{
+		PasswordHash: password{},
 	}
+	query := `
+		SELECT id, username, email, password_hash, COALESCE(bio, ''), activated, created_at, updated_at
+		FROM users
+	` + where
+
+	err := u.db.QueryRow(query, args...).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash.hash,
+		&user.Bio,
+		&user.Activated,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	return user, nil
 }
 
+// UpdateUser saves the profile. Changing the email revokes the pending
+// activation tokens in the same transaction, since they were mailed to the
+// previous address.
 func (u *PostgresUserStore) UpdateUser(user *User) error {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previousEmail string
+	err = tx.QueryRow(`SELECT email FROM users WHERE id = $1 FOR UPDATE`, user.ID).Scan(&previousEmail)
+	if err != nil {
+		return translateError(err)
+	}
+
 	query := `
 		UPDATE users
-		SET username = $1, email = $2, bio = $3, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $4
+		SET username = $1, email = $2, bio = $3, activated = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
 		RETURNING updated_at
 	`
 
-	result, err := u.db.Exec(query, user.Username, user.Email, user.Bio, user.ID)
+	err = tx.QueryRow(query, user.Username, user.Email, user.Bio, user.Activated, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		return duplicateUserError(err)
+	}
+
+	if previousEmail != user.Email {
+		_, err = tx.Exec(`DELETE FROM tokens WHERE user_id = $1 AND scope = $2`, user.ID, tokens.ScopeActivation)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteUser removes the account together with the custom exercises nobody
+// else uses. Exercises other users logged or planned stay behind in the
+// shared catalog so their entries keep resolving.
+func (u *PostgresUserStore) DeleteUser(id int) error {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// program sessions restrict deleting their templates, so the programs
+	// go before the cascade from users reaches the templates
+	_, err = tx.Exec(`DELETE FROM programs WHERE user_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	// locking first holds off new references, and the delete below then
+	// sees every reference committed before the lock was granted
+	_, err = tx.Exec(`SELECT id FROM exercises WHERE user_id = $1 FOR UPDATE`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM exercises e WHERE e.user_id = $1 AND NOT `+exerciseSharedCondition, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	err = requireRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdatePassword stores the new hash and, in the same transaction, revokes
+// every session and pending password reset of the user except the session
+// keepToken belongs to. Pass an empty keepToken to revoke them all.
+func (u *PostgresUserStore) UpdatePassword(user *User, keepToken string) error {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	result, err := tx.Exec(query, user.PasswordHash.hash, user.ID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	err = requireRowsAffected(result)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	hash := sha256.Sum256([]byte(keepToken))
+	query = `
+		DELETE FROM tokens
+		WHERE user_id = $1
+		AND scope IN ($2, $3, $4)
+		AND hash <> $5
+		AND (family_id IS NULL OR family_id NOT IN (
+			SELECT family_id FROM tokens WHERE hash = $5 AND family_id IS NOT NULL
+		))
+	`
+
+	_, err = tx.Exec(query, user.ID, tokens.ScopeAuth, tokens.ScopeRefresh, tokens.ScopePasswordReset, hash[:])
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	return tx.Commit()
+}
+
+func (u *PostgresUserStore) ActivateUser(user *User) error {
+	query := `
+		UPDATE users
+		SET activated = true, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	result, err := u.db.Exec(query, user.ID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	err = requireRowsAffected(result)
+	if err != nil {
+		return err
 	}
 
+	user.Activated = true
 	return nil
 }
 
 func (u *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlainText))
 	query := `
-	SELECT u.id, u.username, u.email, u.bio, u.password_hash
-	FROM users
+	SELECT u.id, u.username, u.email, u.bio, u.password_hash, u.activated
+	FROM users u
 	INNER JOIN tokens t ON t.user_id = u.id
 	WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3
   `
@@ -159,17 +370,12 @@ func (u *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User, e
 		&user.ID,
 		&user.Username,
 		&user.Email,
-		&user.Email,
 		&user.Bio,
 		&user.PasswordHash.hash,
+		&user.Activated,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	return user, nil
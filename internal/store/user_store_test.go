@@ -0,0 +1,212 @@
+package store
+
+import (
+	"go-server/internal/tokens"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivateUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	userStore := NewPostgresUserStore(db)
+	tokenStore := NewPostgresTokenStore(db)
+
+	user := createTestUser(t, db, "newcomer")
+	assert.False(t, user.Activated)
+
+	token, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeActivation)
+	require.NoError(t, err)
+
+	_, err = userStore.GetUserToken(tokens.ScopeAuth, token.PlainText)
+	assert.ErrorIs(t, err, ErrNotFound, "activation tokens do not authenticate")
+
+	found, err := userStore.GetUserToken(tokens.ScopeActivation, token.PlainText)
+	require.NoError(t, err)
+
+	require.NoError(t, userStore.ActivateUser(found))
+	assert.True(t, found.Activated)
+
+	reloaded, err := userStore.GetUserByEmail(user.Email)
+	require.NoError(t, err)
+	assert.True(t, reloaded.Activated)
+}
+
+func TestEmailChangeRevokesActivationTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	userStore := NewPostgresUserStore(db)
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "mover")
+
+	token, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopeActivation)
+	require.NoError(t, err)
+
+	user.Bio = "same address"
+	require.NoError(t, userStore.UpdateUser(user))
+	_, err = userStore.GetUserToken(tokens.ScopeActivation, token.PlainText)
+	assert.NoError(t, err, "other profile changes keep the token")
+
+	user.Email = "moved@example.com"
+	require.NoError(t, userStore.UpdateUser(user))
+	_, err = userStore.GetUserToken(tokens.ScopeActivation, token.PlainText)
+	assert.ErrorIs(t, err, ErrNotFound, "a token mailed to the old address cannot activate the new one")
+}
+
+func TestUpdateAndDeleteUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	userStore := NewPostgresUserStore(db)
+	workoutStore := NewPostgresWorkoutStore(db)
+
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+
+	bob.Username = alice.Username
+	assert.ErrorIs(t, userStore.UpdateUser(bob), ErrDuplicateUsername)
+
+	bob.Username = "bob"
+	bob.Email = alice.Email
+	assert.ErrorIs(t, userStore.UpdateUser(bob), ErrDuplicateEmail)
+
+	bob.Email = "bobby@example.com"
+	bob.Bio = "updated"
+	require.NoError(t, userStore.UpdateUser(bob))
+
+	reloaded, err := userStore.GetUserByUsername("bob")
+	require.NoError(t, err)
+	assert.Equal(t, "bobby@example.com", reloaded.Email)
+	assert.Equal(t, "updated", reloaded.Bio)
+
+	workout, err := workoutStore.CreateWorkout(&Workout{UserID: bob.ID, Title: "Leg day", DurationSeconds: 600})
+	require.NoError(t, err)
+
+	require.NoError(t, userStore.DeleteUser(bob.ID))
+
+	_, err = userStore.GetUserByID(bob.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = workoutStore.GetWorkoutByID(int64(workout.ID))
+	assert.ErrorIs(t, err, ErrNotFound, "workouts cascade with the user")
+}
+
+func TestUpdatePasswordRevokesOtherSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	userStore := NewPostgresUserStore(db)
+	tokenStore := NewPostgresTokenStore(db)
+	user := createTestUser(t, db, "rotator")
+
+	current, err := tokenStore.CreateTokenPair(user.ID, time.Hour, 24*time.Hour, tokens.Client{UserAgent: "phone"})
+	require.NoError(t, err)
+	stolen, err := tokenStore.CreateTokenPair(user.ID, time.Hour, 24*time.Hour, tokens.Client{UserAgent: "laptop"})
+	require.NoError(t, err)
+	reset, err := tokenStore.CreateNewToken(user.ID, time.Hour, tokens.ScopePasswordReset)
+	require.NoError(t, err)
+
+	require.NoError(t, user.PasswordHash.Set("changed password"))
+	require.NoError(t, userStore.UpdatePassword(user, current.Access.PlainText))
+
+	_, err = userStore.GetUserToken(tokens.ScopeAuth, current.Access.PlainText)
+	assert.NoError(t, err)
+	_, err = tokenStore.RotateRefreshToken(current.Refresh.PlainText, time.Hour, 24*time.Hour, tokens.Client{})
+	assert.NoError(t, err, "the session that changed the password stays signed in")
+
+	_, err = userStore.GetUserToken(tokens.ScopeAuth, stolen.Access.PlainText)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = tokenStore.RotateRefreshToken(stolen.Refresh.PlainText, time.Hour, 24*time.Hour, tokens.Client{})
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = userStore.GetUserToken(tokens.ScopePasswordReset, reset.PlainText)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDeleteUserRemovesUnsharedExercises(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, exercises CASCADE")
+	require.NoError(t, err)
+
+	userStore := NewPostgresUserStore(db)
+	exerciseStore := NewPostgresExerciseStore(db)
+	workoutStore := NewPostgresWorkoutStore(db)
+	leaver := createTestUser(t, db, "leaver")
+	stayer := createTestUser(t, db, "stayer")
+
+	private := &Exercise{UserID: IntPtr(leaver.ID), Name: "Private Curl", MovementType: MovementTypeReps}
+	require.NoError(t, exerciseStore.CreateExercise(private))
+	shared := &Exercise{UserID: IntPtr(leaver.ID), Name: "Shared Row", MovementType: MovementTypeReps}
+	require.NoError(t, exerciseStore.CreateExercise(shared))
+
+	_, err = workoutStore.CreateWorkout(&Workout{UserID: leaver.ID, Title: "Arms", Entries: []WorkoutEntry{
+		{ExerciseName: "Private Curl", Sets: 3, Reps: IntPtr(10), OrderIndex: 0},
+	}})
+	require.NoError(t, err)
+	_, err = workoutStore.CreateWorkout(&Workout{UserID: stayer.ID, Title: "Back", Entries: []WorkoutEntry{
+		{ExerciseName: "Shared Row", Sets: 3, Reps: IntPtr(10), OrderIndex: 0},
+	}})
+	require.NoError(t, err)
+
+	require.NoError(t, userStore.DeleteUser(leaver.ID))
+
+	_, err = exerciseStore.GetExerciseByID(int64(private.ID))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	kept, err := exerciseStore.GetExerciseByID(int64(shared.ID))
+	require.NoError(t, err)
+	assert.Nil(t, kept.UserID)
+}
+
+func TestDeleteUserWithPrograms(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users, exercises CASCADE")
+	require.NoError(t, err)
+
+	userStore := NewPostgresUserStore(db)
+	templateStore := NewPostgresTemplateStore(db)
+	programStore := NewPostgresProgramStore(db)
+	coach := createTestUser(t, db, "retiring coach")
+
+	template := &WorkoutTemplate{
+		UserID:  coach.ID,
+		Name:    "Full body",
+		Entries: []TemplateEntry{{ExerciseName: "Burpee", TargetSets: 3, TargetReps: IntPtr(10)}},
+	}
+	require.NoError(t, templateStore.CreateTemplate(template))
+
+	program := &Program{
+		UserID:   coach.ID,
+		Name:     "Conditioning",
+		Weeks:    1,
+		Sessions: []ProgramSession{{Week: 1, Day: 1, TemplateID: template.ID}, {Week: 1, Day: 3, TemplateID: template.ID}},
+	}
+	require.NoError(t, programStore.CreateProgram(program))
+
+	require.NoError(t, userStore.DeleteUser(coach.ID))
+
+	_, err = programStore.GetProgramByID(int64(program.ID))
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = templateStore.GetTemplateByID(int64(template.ID))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
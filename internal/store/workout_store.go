@@ -2,7 +2,15 @@ package store
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"go-server/internal/strength"
+	"go-server/internal/validator"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Workout struct {
@@ -12,26 +20,220 @@ type Workout struct {
 	Description     string         `json:"description"`
 	DurationSeconds int            `json:"duration_seconds"`
 	CaloriesBurned  int            `json:"calories_burned"`
+	SessionRPE      *float64       `json:"session_rpe"`
+	Visibility      string         `json:"visibility"`
+	Version         int            `json:"version"`
+	CreatedAt       time.Time      `json:"created_at"`
+	DeletedAt       *time.Time     `json:"deleted_at,omitempty"`
 	Entries         []WorkoutEntry `json:"entries"`
 }
 
 type WorkoutEntry struct {
 	ID              int      `json:"id"`
+	ExerciseID      *int     `json:"exercise_id"`
 	ExerciseName    string   `json:"exercise_name"`
 	Sets            int      `json:"sets"`
 	Reps            *int     `json:"reps"`
 	DurationSeconds *int     `json:"duration_seconds"`
 	Weight          *float64 `json:"weight"`
+	RPE             *float64 `json:"rpe"`
 	Notes           string   `json:"notes"`
 	OrderIndex      int      `json:"order_index"`
 }
 
+const (
+	VisibilityPrivate   = "private"
+	VisibilityFollowers = "followers"
+	VisibilityPublic    = "public"
+)
+
+const (
+	MaxWorkoutEntries  = 100
+	maxDurationSeconds = 24 * 60 * 60
+	// weight is stored as DECIMAL(5, 2)
+	maxEntryWeight = strength.MaxWeight
+)
+
+// VisibleTo reports whether a viewer may read the workout. viewerID is 0
+// for anonymous requests; following says whether the viewer follows the owner.
+func (w *Workout) VisibleTo(viewerID int, following bool) bool {
+	switch {
+	case viewerID != 0 && viewerID == w.UserID:
+		return true
+	case w.Visibility == VisibilityPublic:
+		return true
+	case w.Visibility == VisibilityFollowers:
+		return viewerID != 0 && following
+	default:
+		return false
+	}
+}
+
+func ValidateWorkout(v *validator.Validator, workout *Workout) {
+	v.Check(validator.NotBlank(workout.Title), "title", "is required")
+	v.Check(validator.MaxChars(workout.Title, 255), "title", "cannot exceed 255 characters")
+	v.Check(validator.MaxChars(workout.Description, 10000), "description", "cannot exceed 10000 characters")
+	v.Check(validator.Between(workout.DurationSeconds, 1, maxDurationSeconds), "duration_seconds", "must be between 1 and 86400")
+	v.Check(validator.Between(workout.CaloriesBurned, 0, 100000), "calories_burned", "must be between 0 and 100000")
+	if workout.SessionRPE != nil {
+		v.Check(validator.Between(*workout.SessionRPE, 1, 10), "session_rpe", "must be between 1 and 10")
+		v.Check(validator.MaxDecimalPlaces(*workout.SessionRPE, 1), "session_rpe", "cannot have more than 1 decimal place")
+	}
+	v.Check(validator.PermittedValue(workout.Visibility, VisibilityPrivate, VisibilityFollowers, VisibilityPublic), "visibility", "must be one of private, followers or public")
+	v.Check(len(workout.Entries) <= MaxWorkoutEntries, "entries", fmt.Sprintf("cannot contain more than %d entries", MaxWorkoutEntries))
+
+	orderIndexes := make([]int, 0, len(workout.Entries))
+	entryIDs := make([]int, 0, len(workout.Entries))
+	for i := range workout.Entries {
+		ValidateWorkoutEntry(v, fmt.Sprintf("entries[%d].", i), &workout.Entries[i])
+		orderIndexes = append(orderIndexes, workout.Entries[i].OrderIndex)
+		if workout.Entries[i].ID != 0 {
+			entryIDs = append(entryIDs, workout.Entries[i].ID)
+		}
+	}
+	v.Check(validator.Unique(orderIndexes), "entries", "order_index must be unique within a workout")
+	v.Check(validator.Unique(entryIDs), "entries", "cannot contain the same entry id twice")
+}
+
+// ValidateWorkoutEntry mirrors the valid_workout_entry constraint and the
+// column types, so bad entries are reported per field instead of failing
+// the insert. prefix locates the entry in the request, e.g. "entries[2].".
+func ValidateWorkoutEntry(v *validator.Validator, prefix string, entry *WorkoutEntry) {
+	if entry.ExerciseID == nil {
+		v.Check(validator.NotBlank(entry.ExerciseName), prefix+"exercise_name", "is required unless exercise_id is set")
+	}
+	v.Check(validator.MaxChars(entry.ExerciseName, 255), prefix+"exercise_name", "cannot exceed 255 characters")
+	v.Check(validator.Between(entry.Sets, 1, 100), prefix+"sets", "must be between 1 and 100")
+
+	switch {
+	case entry.Reps == nil && entry.DurationSeconds == nil:
+		v.AddError(prefix+"reps", "either reps or duration_seconds is required")
+	case entry.Reps != nil && entry.DurationSeconds != nil:
+		v.AddError(prefix+"reps", "cannot be combined with duration_seconds")
+	case entry.Reps != nil:
+		v.Check(validator.Between(*entry.Reps, 1, 1000), prefix+"reps", "must be between 1 and 1000")
+	default:
+		v.Check(validator.Between(*entry.DurationSeconds, 1, maxDurationSeconds), prefix+"duration_seconds", "must be between 1 and 86400")
+	}
+
+	if entry.Weight != nil {
+		v.Check(validator.Between(*entry.Weight, 0, maxEntryWeight), prefix+"weight", "must be between 0 and 999.99")
+		v.Check(validator.MaxDecimalPlaces(*entry.Weight, 2), prefix+"weight", "cannot have more than 2 decimal places")
+	}
+
+	if entry.RPE != nil {
+		v.Check(validator.Between(*entry.RPE, 1, 10), prefix+"rpe", "must be between 1 and 10")
+		v.Check(validator.MaxDecimalPlaces(*entry.RPE, 1), prefix+"rpe", "cannot have more than 1 decimal place")
+	}
+
+	v.Check(validator.MaxChars(entry.Notes, 1000), prefix+"notes", "cannot exceed 1000 characters")
+	v.Check(entry.OrderIndex >= 0, prefix+"order_index", "cannot be negative")
+}
+
 type WorkoutStore interface {
 	CreateWorkout(*Workout) (*Workout, error)
 	GetWorkoutByID(id int64) (*Workout, error)
 	UpdateWorkout(*Workout) error
-	DeleteWorkout(id int64) error
+	DeleteWorkout(id int64, version int) error
+	RestoreWorkout(id int64, userID int) error
+	PurgeDeletedWorkouts(before time.Time) (int64, error)
 	GetWorkoutOwner(id int64) (int, error)
+	ListWorkouts(filter WorkoutFilter) (*WorkoutPage, error)
+	GetWorkoutEntry(workoutID, entryID int64) (*WorkoutEntry, error)
+	CreateWorkoutEntry(workoutID int64, version int, entry *WorkoutEntry) (int, error)
+	UpdateWorkoutEntry(workoutID int64, version int, entry *WorkoutEntry) (int, error)
+	DeleteWorkoutEntry(workoutID int64, version int, entryID int64) (int, error)
+	ReorderWorkoutEntries(workoutID int64, version int, entryIDs []int) (int, error)
+}
+
+const (
+	DefaultWorkoutPageSize = 20
+	MaxWorkoutPageSize     = 100
+)
+
+var (
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrUnknownEntry   = errors.New("entry does not belong to this workout")
+	ErrTooManyEntries = fmt.Errorf("workout cannot contain more than %d entries", MaxWorkoutEntries)
+)
+
+type WorkoutFilter struct {
+	UserID      int
+	Trashed     bool
+	From        *time.Time
+	To          *time.Time
+	Title       string
+	MinDuration *int
+	MaxDuration *int
+	MinCalories *int
+	MaxCalories *int
+	Sort        string
+	Cursor      string
+	Limit       int
+}
+
+type WorkoutPage struct {
+	Workouts   []*Workout `json:"workouts"`
+	NextCursor string     `json:"next_cursor"`
+	TotalCount int        `json:"total_count"`
+}
+
+type workoutSortColumn struct {
+	column string
+	cast   string
+}
+
+var workoutSortColumns = map[string]workoutSortColumn{
+	"created_at":       {column: "created_at", cast: "timestamptz"},
+	"title":            {column: "title", cast: "text"},
+	"duration_seconds": {column: "duration_seconds", cast: "integer"},
+	"calories_burned":  {column: "calories_burned", cast: "integer"},
+}
+
+func ValidWorkoutSort(sort string) bool {
+	_, ok := workoutSortColumns[strings.TrimPrefix(sort, "-")]
+	return ok
+}
+
+// workoutCursor remembers the sort it was issued for, since its value only
+// fits the cast of that sort's column.
+type workoutCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func encodeWorkoutCursor(sort, value string, id int) string {
+	js, _ := json.Marshal(workoutCursor{Sort: sort, Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeWorkoutCursor(cursor, sort string) (*workoutCursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c workoutCursor
+	if err := json.Unmarshal(js, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+
+	switch workoutSortColumns[strings.TrimPrefix(sort, "-")].cast {
+	case "integer":
+		_, err = strconv.ParseInt(c.Value, 10, 32)
+	case "timestamptz":
+		_, err = time.Parse(time.RFC3339Nano, c.Value)
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
 }
 
 type PostgresWorkoutStore struct {
@@ -49,64 +251,75 @@ func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error
 	}
 	defer tx.Rollback()
 
+	err = insertWorkout(tx, workout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return workout, nil
+}
+
+// insertWorkout inserts the workout with its entries and updates the user's
+// records, so other stores can create a workout inside their transaction.
+func insertWorkout(tx *sql.Tx, workout *Workout) error {
+	if workout.Visibility == "" {
+		workout.Visibility = VisibilityPrivate
+	}
+
 	query :=
 		`
-		INSERT INTO workouts (user_id, title, description, duration_seconds, calories_burned)
-		VALUES ($1,$2,$3,$4, $5)
-		RETURNING id;
+		INSERT INTO workouts (user_id, title, description, duration_seconds, calories_burned, session_rpe, visibility)
+		VALUES ($1,$2,$3,$4, $5, $6, $7)
+		RETURNING id, version, created_at;
 	`
 
-	err = tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationSeconds, workout.CaloriesBurned).Scan(&workout.ID)
+	err := tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationSeconds, workout.CaloriesBurned, workout.SessionRPE, workout.Visibility).Scan(&workout.ID, &workout.Version, &workout.CreatedAt)
 	if err != nil {
-		return nil, err
+		return translateError(err)
 	}
 
-	for _, entry := range workout.Entries {
-		query := `
-			INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-			RETURNING id
-		`
-
-		err = tx.QueryRow(query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	for i := range workout.Entries {
+		err = insertWorkoutEntry(tx, int64(workout.ID), &workout.Entries[i])
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return nil, err
-	}
-
-	return workout, nil
+	return recomputePersonalRecords(tx, workout.UserID, entryExerciseIDs(workout.Entries))
 }
 
 func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 	workout := &Workout{}
 
 	query := `
-		SELECT id, title, description, duration_seconds, calories_burned
+		SELECT id, user_id, title, COALESCE(description, ''), duration_seconds, COALESCE(calories_burned, 0), session_rpe, visibility, version, created_at
 		FROM workouts
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	err := pg.db.QueryRow(query, id).Scan(
 		&workout.ID,
+		&workout.UserID,
 		&workout.Title,
 		&workout.Description,
 		&workout.DurationSeconds,
 		&workout.CaloriesBurned,
+		&workout.SessionRPE,
+		&workout.Visibility,
+		&workout.Version,
+		&workout.CreatedAt,
 	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	entriesQuery := `
-		SELECT id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index
+		SELECT id, exercise_id, exercise_name, sets, reps, duration_seconds, weight, rpe, COALESCE(notes, ''), order_index
 		FROM workout_entries
 		WHERE workout_id = $1
 		ORDER BY order_index
@@ -122,17 +335,16 @@ func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 		var entry WorkoutEntry
 		err := rows.Scan(
 			&entry.ID,
+			&entry.ExerciseID,
 			&entry.ExerciseName,
 			&entry.Sets,
 			&entry.Reps,
 			&entry.DurationSeconds,
 			&entry.Weight,
+			&entry.RPE,
 			&entry.Notes,
 			&entry.OrderIndex,
 		)
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
 		if err != nil {
 			return nil, err
 		}
@@ -156,75 +368,598 @@ func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
 
 	query := `
 		UPDATE workouts 
-		SET title = $1, description = $2, duration_seconds = $3, calories_burned = $4 
-		WHERE id = $5
+		SET title = $1, description = $2, duration_seconds = $3, calories_burned = $4, session_rpe = $5, visibility = $6, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $7 AND version = $8 AND deleted_at IS NULL
+		RETURNING user_id, version, created_at
 	`
 
-	result, err := tx.Exec(query, workout.Title, workout.Description, workout.DurationSeconds, workout.CaloriesBurned, workout.ID)
+	err = tx.QueryRow(query, workout.Title, workout.Description, workout.DurationSeconds, workout.CaloriesBurned, workout.SessionRPE, workout.Visibility, workout.ID, workout.Version).Scan(&workout.UserID, &workout.Version, &workout.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return staleWorkoutError(tx, int64(workout.ID))
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	previousExerciseIDs, err := workoutExerciseIDs(tx, int64(workout.ID))
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+
+	// Entries are diffed rather than replaced so their ids, created_at and the
+	// personal records pointing at them survive an edit.
+	existingIDs, err := workoutEntryIDs(tx, int64(workout.ID))
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+
+	keptIDs := []int{}
+	for _, entry := range workout.Entries {
+		if entry.ID == 0 {
+			continue
+		}
+		if !existingIDs[entry.ID] {
+			return ErrUnknownEntry
+		}
+		keptIDs = append(keptIDs, entry.ID)
 	}
 
-	_, err = tx.Exec("DELETE FROM workout_entries WHERE workout_id = $1", workout.ID)
+	_, err = tx.Exec(`DELETE FROM workout_entries WHERE workout_id = $1 AND NOT (id = ANY($2))`, workout.ID, keptIDs)
 	if err != nil {
 		return err
 	}
 
-	for _, workoutEntry := range workout.Entries {
-		query = `
-			INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		`
+	for i := range workout.Entries {
+		if workout.Entries[i].ID == 0 {
+			err = insertWorkoutEntry(tx, int64(workout.ID), &workout.Entries[i])
+		} else {
+			err = updateWorkoutEntry(tx, int64(workout.ID), &workout.Entries[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	exerciseIDs := append(previousExerciseIDs, entryExerciseIDs(workout.Entries)...)
+	err = recomputePersonalRecords(tx, workout.UserID, exerciseIDs)
+	if err != nil {
+		return err
+	}
+
+	return translateError(tx.Commit())
+}
+
+func insertWorkoutEntry(tx *sql.Tx, workoutID int64, entry *WorkoutEntry) error {
+	err := resolveEntryExercise(tx, entry)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO workout_entries (workout_id, exercise_id, exercise_name, sets, reps, duration_seconds, weight, rpe, notes, order_index)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+
+	err = tx.QueryRow(query, workoutID, entry.ExerciseID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.RPE, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	return translateError(err)
+}
+
+func updateWorkoutEntry(tx *sql.Tx, workoutID int64, entry *WorkoutEntry) error {
+	err := resolveEntryExercise(tx, entry)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE workout_entries
+		SET exercise_id = $1, exercise_name = $2, sets = $3, reps = $4, duration_seconds = $5, weight = $6, rpe = $7, notes = $8, order_index = $9, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $10 AND workout_id = $11
+	`
+
+	result, err := tx.Exec(query, entry.ExerciseID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.RPE, entry.Notes, entry.OrderIndex, entry.ID, workoutID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return requireRowsAffected(result)
+}
+
+func workoutEntryIDs(tx *sql.Tx, workoutID int64) (map[int]bool, error) {
+	rows, err := tx.Query(`SELECT id FROM workout_entries WHERE workout_id = $1`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+
+	return ids, rows.Err()
+}
+
+// editWorkoutEntries runs edit while holding the workout row lock, so
+// concurrent entry edits cannot interleave, and then recomputes records for
+// every exercise the workout referenced before or after the edit. Entries
+// are part of the workout's representation, so the edit bumps its version;
+// the new version is returned.
+func (pg *PostgresWorkoutStore) editWorkoutEntries(workoutID int64, version int, edit func(tx *sql.Tx) error) (int, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID, currentVersion int
+	err = tx.QueryRow(`SELECT user_id, version FROM workouts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, workoutID).Scan(&userID, &currentVersion)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	if currentVersion != version {
+		return 0, ErrEditConflict
+	}
+
+	previousExerciseIDs, err := workoutExerciseIDs(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = edit(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	exerciseIDs, err := workoutExerciseIDs(tx, workoutID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = recomputePersonalRecords(tx, userID, append(previousExerciseIDs, exerciseIDs...))
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		UPDATE workouts SET updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1
+		RETURNING version
+	`
+
+	err = tx.QueryRow(query, workoutID).Scan(&currentVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	return currentVersion, translateError(tx.Commit())
+}
+
+func (pg *PostgresWorkoutStore) GetWorkoutEntry(workoutID, entryID int64) (*WorkoutEntry, error) {
+	entry := &WorkoutEntry{}
+
+	query := `
+		SELECT id, exercise_id, exercise_name, sets, reps, duration_seconds, weight, rpe, COALESCE(notes, ''), order_index
+		FROM workout_entries
+		WHERE id = $1 AND workout_id = $2
+	`
 
-		_, err = tx.Exec(query, workout.ID, workoutEntry.ExerciseName, workoutEntry.Sets, workoutEntry.Reps, workoutEntry.DurationSeconds, workoutEntry.Weight, workoutEntry.Notes, workoutEntry.OrderIndex)
+	err := pg.db.QueryRow(query, entryID, workoutID).Scan(
+		&entry.ID,
+		&entry.ExerciseID,
+		&entry.ExerciseName,
+		&entry.Sets,
+		&entry.Reps,
+		&entry.DurationSeconds,
+		&entry.Weight,
+		&entry.RPE,
+		&entry.Notes,
+		&entry.OrderIndex,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return entry, nil
+}
+
+// CreateWorkoutEntry appends the entry after the workout's last entry; its
+// OrderIndex is overwritten. Use ReorderWorkoutEntries to move it.
+func (pg *PostgresWorkoutStore) CreateWorkoutEntry(workoutID int64, version int, entry *WorkoutEntry) (int, error) {
+	return pg.editWorkoutEntries(workoutID, version, func(tx *sql.Tx) error {
+		var count int
+		query := `SELECT COUNT(*), COALESCE(MAX(order_index) + 1, 0) FROM workout_entries WHERE workout_id = $1`
+		err := tx.QueryRow(query, workoutID).Scan(&count, &entry.OrderIndex)
 		if err != nil {
 			return err
 		}
+		if count >= MaxWorkoutEntries {
+			return ErrTooManyEntries
+		}
+
+		return insertWorkoutEntry(tx, workoutID, entry)
+	})
+}
+
+func (pg *PostgresWorkoutStore) UpdateWorkoutEntry(workoutID int64, version int, entry *WorkoutEntry) (int, error) {
+	return pg.editWorkoutEntries(workoutID, version, func(tx *sql.Tx) error {
+		return updateWorkoutEntry(tx, workoutID, entry)
+	})
+}
+
+func (pg *PostgresWorkoutStore) DeleteWorkoutEntry(workoutID int64, version int, entryID int64) (int, error) {
+	return pg.editWorkoutEntries(workoutID, version, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM workout_entries WHERE id = $1 AND workout_id = $2`, entryID, workoutID)
+		if err != nil {
+			return err
+		}
+
+		return requireRowsAffected(result)
+	})
+}
+
+// ReorderWorkoutEntries sets each entry's order_index to its position in
+// entryIDs, which must list every entry of the workout exactly once.
+func (pg *PostgresWorkoutStore) ReorderWorkoutEntries(workoutID int64, version int, entryIDs []int) (int, error) {
+	return pg.editWorkoutEntries(workoutID, version, func(tx *sql.Tx) error {
+		existingIDs, err := workoutEntryIDs(tx, workoutID)
+		if err != nil {
+			return err
+		}
+
+		if len(entryIDs) != len(existingIDs) {
+			return ErrUnknownEntry
+		}
+		for _, id := range entryIDs {
+			if !existingIDs[id] {
+				return ErrUnknownEntry
+			}
+			delete(existingIDs, id)
+		}
+
+		query := `
+			UPDATE workout_entries we
+			SET order_index = ordered.position - 1, updated_at = CURRENT_TIMESTAMP
+			FROM unnest($2::bigint[]) WITH ORDINALITY AS ordered(id, position)
+			WHERE we.id = ordered.id AND we.workout_id = $1
+		`
+
+		_, err = tx.Exec(query, workoutID, entryIDs)
+		return err
+	})
+}
+
+func (pg *PostgresWorkoutStore) DeleteWorkout(id int64, version int) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exerciseIDs, err := workoutExerciseIDs(tx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE workouts SET deleted_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+		RETURNING user_id
+	`
+
+	var userID int
+	err = tx.QueryRow(query, id, version).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return staleWorkoutError(tx, id)
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	err = recomputePersonalRecords(tx, userID, exerciseIDs)
+	if err != nil {
+		return err
 	}
 
 	return tx.Commit()
 }
 
-func (pg *PostgresWorkoutStore) DeleteWorkout(id int64) error {
+// RestoreWorkout takes a workout out of userID's trash. Workouts that are not
+// in that user's trash are reported as ErrNotFound.
+func (pg *PostgresWorkoutStore) RestoreWorkout(id int64, userID int) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-		DELETE FROM workouts WHERE id = $1
+		UPDATE workouts SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
 	`
 
-	result, err := pg.db.Exec(query, id)
+	result, err := tx.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	err = requireRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	exerciseIDs, err := workoutExerciseIDs(tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = recomputePersonalRecords(tx, userID, exerciseIDs)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	return tx.Commit()
+}
+
+// PurgeDeletedWorkouts permanently removes workouts trashed before the given
+// time. Their entries and records go with them through ON DELETE CASCADE.
+func (pg *PostgresWorkoutStore) PurgeDeletedWorkouts(before time.Time) (int64, error) {
+	result, err := pg.db.Exec(`DELETE FROM workouts WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
 	}
+
+	return result.RowsAffected()
+}
+
+// staleWorkoutError explains why a write conditioned on a workout's version
+// matched no row: the workout is gone, or someone else changed it first.
+func staleWorkoutError(q queryRower, id int64) error {
+	var exists bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM workouts WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
 	if err != nil {
 		return err
 	}
+	if exists {
+		return ErrEditConflict
+	}
+
+	return ErrNotFound
+}
+
+func workoutExerciseIDs(tx *sql.Tx, workoutID int64) ([]int, error) {
+	rows, err := tx.Query(`SELECT DISTINCT exercise_id FROM workout_entries WHERE workout_id = $1 AND exercise_id IS NOT NULL`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	fmt.Println("Rows affected", rowsAffected)
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
 
-	return nil
+	return ids, rows.Err()
 }
 
 func (pg *PostgresWorkoutStore) GetWorkoutOwner(workoutId int64) (int, error) {
 	var userId int
 	query := `
-		SELECT user_id from workouts WHERE id = $1
+		SELECT user_id from workouts WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	err := pg.db.QueryRow(query, workoutId).Scan(&userId)
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 
 	return userId, nil
 }
+
+// likeEscaper makes a search term match literally inside a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (pg *PostgresWorkoutStore) ListWorkouts(filter WorkoutFilter) (*WorkoutPage, error) {
+	if filter.Sort == "" {
+		filter.Sort = "-created_at"
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultWorkoutPageSize
+	}
+	if filter.Limit > MaxWorkoutPageSize {
+		filter.Limit = MaxWorkoutPageSize
+	}
+
+	descending := strings.HasPrefix(filter.Sort, "-")
+	sortColumn, ok := workoutSortColumns[strings.TrimPrefix(filter.Sort, "-")]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort %q", filter.Sort)
+	}
+
+	conditions := []string{"user_id = $1", "deleted_at IS NULL"}
+	if filter.Trashed {
+		conditions[1] = "deleted_at IS NOT NULL"
+	}
+	args := []any{filter.UserID}
+	addCondition := func(format string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+	if filter.Title != "" {
+		addCondition(`title ILIKE '%%' || $%d || '%%' ESCAPE '\'`, likeEscaper.Replace(filter.Title))
+	}
+	if filter.MinDuration != nil {
+		addCondition("duration_seconds >= $%d", *filter.MinDuration)
+	}
+	if filter.MaxDuration != nil {
+		addCondition("duration_seconds <= $%d", *filter.MaxDuration)
+	}
+	if filter.MinCalories != nil {
+		addCondition("calories_burned >= $%d", *filter.MinCalories)
+	}
+	if filter.MaxCalories != nil {
+		addCondition("calories_burned <= $%d", *filter.MaxCalories)
+	}
+
+	page := &WorkoutPage{Workouts: []*Workout{}}
+
+	countQuery := "SELECT COUNT(*) FROM workouts WHERE " + strings.Join(conditions, " AND ")
+	err := pg.db.QueryRow(countQuery, args...).Scan(&page.TotalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	sortExpression := sortColumn.column
+	if sortColumn.cast == "integer" {
+		sortExpression = fmt.Sprintf("COALESCE(%s, 0)", sortColumn.column)
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeWorkoutCursor(filter.Cursor, filter.Sort)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, cursor.Value, cursor.ID)
+		conditions = append(conditions, fmt.Sprintf(
+			"(%s, id) %s ($%d::%s, $%d)",
+			sortExpression, comparison, len(args)-1, sortColumn.cast, len(args),
+		))
+	}
+
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT id, user_id, title, description, duration_seconds, COALESCE(calories_burned, 0), session_rpe, visibility, version, created_at, deleted_at
+		FROM workouts
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT $%d
+	`, strings.Join(conditions, " AND "), sortExpression, direction, direction, len(args))
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workoutsByID := map[int]*Workout{}
+	ids := []int64{}
+	for rows.Next() {
+		workout := &Workout{}
+		var description sql.NullString
+		err := rows.Scan(
+			&workout.ID,
+			&workout.UserID,
+			&workout.Title,
+			&description,
+			&workout.DurationSeconds,
+			&workout.CaloriesBurned,
+			&workout.SessionRPE,
+			&workout.Visibility,
+			&workout.Version,
+			&workout.CreatedAt,
+			&workout.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		workout.Description = description.String
+
+		page.Workouts = append(page.Workouts, workout)
+		workoutsByID[workout.ID] = workout
+		ids = append(ids, int64(workout.ID))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Workouts) > filter.Limit {
+		page.Workouts = page.Workouts[:filter.Limit]
+		last := page.Workouts[len(page.Workouts)-1]
+		page.NextCursor = encodeWorkoutCursor(filter.Sort, workoutSortValue(last, sortColumn.column), last.ID)
+		ids = ids[:filter.Limit]
+	}
+
+	if len(ids) == 0 {
+		return page, nil
+	}
+
+	entriesQuery := `
+		SELECT id, workout_id, exercise_id, exercise_name, sets, reps, duration_seconds, weight, rpe, notes, order_index
+		FROM workout_entries
+		WHERE workout_id = ANY($1)
+		ORDER BY workout_id, order_index
+	`
+
+	entryRows, err := pg.db.Query(entriesQuery, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer entryRows.Close()
+
+	for entryRows.Next() {
+		var entry WorkoutEntry
+		var workoutID int
+		var notes sql.NullString
+		err := entryRows.Scan(
+			&entry.ID,
+			&workoutID,
+			&entry.ExerciseID,
+			&entry.ExerciseName,
+			&entry.Sets,
+			&entry.Reps,
+			&entry.DurationSeconds,
+			&entry.Weight,
+			&entry.RPE,
+			&notes,
+			&entry.OrderIndex,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.Notes = notes.String
+
+		if workout, ok := workoutsByID[workoutID]; ok {
+			workout.Entries = append(workout.Entries, entry)
+		}
+	}
+
+	if err = entryRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func workoutSortValue(workout *Workout, column string) string {
+	switch column {
+	case "title":
+		return workout.Title
+	case "duration_seconds":
+		return fmt.Sprint(workout.DurationSeconds)
+	case "calories_burned":
+		return fmt.Sprint(workout.CaloriesBurned)
+	default:
+		return workout.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
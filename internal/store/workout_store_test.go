@@ -2,15 +2,23 @@ package store
 
 import (
 	"database/sql"
+	"go-server/internal/validator"
+	"os"
 	"testing"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("pgx", "host=localhost user=postgres password=postgres dbname=postgres sslmode=disable port=5433")
+func setupTestDB(t testing.TB) *sql.DB {
+	dsn := os.Getenv("GYM_TEST_DB_DSN")
+	if dsn == "" {
+		dsn = "host=localhost user=postgres password=postgres dbname=postgres sslmode=disable port=5433"
+	}
+
+	db, err := sql.Open("pgx", dsn)
 	if err != nil {
 		t.Fatal("opening test db %w", err)
 	}
@@ -120,6 +128,381 @@ func TestCreateWorkout(t *testing.T) {
 	}
 }
 
+func createTestUser(t testing.TB, db *sql.DB, username string) *User {
+	userStore := NewPostgresUserStore(db)
+	user := &User{
+		Username: username,
+		Email:    username + "@example.com",
+		Bio:      "test user",
+	}
+
+	err := user.PasswordHash.Set("password")
+	require.NoError(t, err)
+
+	err = userStore.CreateUser(user)
+	require.NoError(t, err)
+
+	return user
+}
+
+func TestListWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "lister")
+	other := createTestUser(t, db, "other")
+
+	for i, title := range []string{"Push day", "Pull day", "Leg day", "Push day 2", "Cardio"} {
+		_, err := store.CreateWorkout(&Workout{
+			UserID:          user.ID,
+			Title:           title,
+			DurationSeconds: 600 * (i + 1),
+			CaloriesBurned:  100 * (i + 1),
+		})
+		require.NoError(t, err)
+	}
+
+	_, err = store.CreateWorkout(&Workout{UserID: other.ID, Title: "Push day", DurationSeconds: 600})
+	require.NoError(t, err)
+
+	page, err := store.ListWorkouts(WorkoutFilter{UserID: user.ID, Sort: "duration_seconds", Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 5, page.TotalCount)
+	require.Len(t, page.Workouts, 2)
+	assert.Equal(t, "Push day", page.Workouts[0].Title)
+	assert.NotEmpty(t, page.NextCursor)
+
+	seen := len(page.Workouts)
+	for page.NextCursor != "" {
+		page, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Sort: "duration_seconds", Limit: 2, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		seen += len(page.Workouts)
+	}
+	assert.Equal(t, 5, seen)
+
+	page, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Title: "push", MinDuration: IntPtr(1000)})
+	require.NoError(t, err)
+	require.Len(t, page.Workouts, 1)
+	assert.Equal(t, "Push day 2", page.Workouts[0].Title)
+
+	for _, wildcard := range []string{"%", "_", "day_"} {
+		page, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Title: wildcard})
+		require.NoError(t, err)
+		assert.Empty(t, page.Workouts, "%q matches literally", wildcard)
+	}
+
+	_, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	page, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Sort: "title", Limit: 1})
+	require.NoError(t, err)
+	_, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Sort: "duration_seconds", Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestDecodeWorkoutCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		sort    string
+		wantErr bool
+	}{
+		{name: "same sort", cursor: encodeWorkoutCursor("title", "Push day", 1), sort: "title"},
+		{name: "other column", cursor: encodeWorkoutCursor("title", "Push day", 1), sort: "duration_seconds", wantErr: true},
+		{name: "other direction", cursor: encodeWorkoutCursor("-title", "Push day", 1), sort: "title", wantErr: true},
+		{name: "integer", cursor: encodeWorkoutCursor("-calories_burned", "400", 1), sort: "-calories_burned"},
+		{name: "not an integer", cursor: encodeWorkoutCursor("duration_seconds", "Push day", 1), sort: "duration_seconds", wantErr: true},
+		{name: "timestamp", cursor: encodeWorkoutCursor("-created_at", "2025-01-02T10:00:00.5Z", 1), sort: "-created_at"},
+		{name: "not a timestamp", cursor: encodeWorkoutCursor("-created_at", "yesterday", 1), sort: "-created_at", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeWorkoutCursor(tt.cursor, tt.sort)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidCursor)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWorkoutEntryEdits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "editor")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          user.ID,
+		Title:           "Push day",
+		DurationSeconds: 3600,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(8), OrderIndex: 0},
+			{ExerciseName: "Dips", Sets: 3, Reps: IntPtr(12), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+	bench, dips := workout.Entries[0].ID, workout.Entries[1].ID
+
+	// keep bench, drop dips, add a new entry and swap positions
+	workout.Entries = []WorkoutEntry{
+		{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(60), OrderIndex: 0},
+		{ID: bench, ExerciseName: "Bench Press", Sets: 4, Reps: IntPtr(6), OrderIndex: 1},
+	}
+	require.NoError(t, store.UpdateWorkout(workout))
+
+	retrieved, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Entries, 2)
+	assert.Equal(t, bench, retrieved.Entries[1].ID)
+	assert.Equal(t, 4, retrieved.Entries[1].Sets)
+	plank := retrieved.Entries[0].ID
+
+	workout.Entries = []WorkoutEntry{{ID: dips, ExerciseName: "Dips", Sets: 3, Reps: IntPtr(12)}}
+	assert.ErrorIs(t, store.UpdateWorkout(workout), ErrUnknownEntry)
+
+	entry := &WorkoutEntry{ExerciseName: "Push Up", Sets: 2, Reps: IntPtr(20)}
+	version, err := store.CreateWorkoutEntry(int64(workout.ID), workout.Version, entry)
+	require.NoError(t, err)
+	assert.Equal(t, workout.Version+1, version)
+	assert.Equal(t, 2, entry.OrderIndex)
+
+	version, err = store.ReorderWorkoutEntries(int64(workout.ID), version, []int{entry.ID, bench, plank})
+	require.NoError(t, err)
+	_, err = store.ReorderWorkoutEntries(int64(workout.ID), version, []int{bench, plank})
+	assert.ErrorIs(t, err, ErrUnknownEntry)
+
+	retrieved, err = store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	assert.Equal(t, version, retrieved.Version)
+	assert.Equal(t, []int{entry.ID, bench, plank}, []int{retrieved.Entries[0].ID, retrieved.Entries[1].ID, retrieved.Entries[2].ID})
+
+	version, err = store.DeleteWorkoutEntry(int64(workout.ID), version, int64(plank))
+	require.NoError(t, err)
+	_, err = store.DeleteWorkoutEntry(int64(workout.ID), version, int64(plank))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestWorkoutVersionConflicts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "versioned")
+
+	workout, err := store.CreateWorkout(&Workout{UserID: user.ID, Title: "Push day", DurationSeconds: 600})
+	require.NoError(t, err)
+	assert.Equal(t, 1, workout.Version)
+
+	stale := *workout
+	workout.Title = "Push day A"
+	require.NoError(t, store.UpdateWorkout(workout))
+	assert.Equal(t, 2, workout.Version)
+
+	stale.Title = "Push day B"
+	assert.ErrorIs(t, store.UpdateWorkout(&stale), ErrEditConflict)
+
+	_, err = store.CreateWorkoutEntry(int64(workout.ID), 1, &WorkoutEntry{ExerciseName: "Dips", Sets: 3, Reps: IntPtr(10)})
+	assert.ErrorIs(t, err, ErrEditConflict)
+
+	assert.ErrorIs(t, store.DeleteWorkout(int64(workout.ID), 1), ErrEditConflict)
+	require.NoError(t, store.DeleteWorkout(int64(workout.ID), workout.Version))
+	assert.ErrorIs(t, store.DeleteWorkout(int64(workout.ID), workout.Version), ErrNotFound)
+}
+
+func TestWorkoutTrash(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("TRUNCATE users CASCADE")
+	require.NoError(t, err)
+
+	store := NewPostgresWorkoutStore(db)
+	user := createTestUser(t, db, "trasher")
+	other := createTestUser(t, db, "bystander")
+
+	workout, err := store.CreateWorkout(&Workout{UserID: user.ID, Title: "Leg day", DurationSeconds: 600})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteWorkout(int64(workout.ID), workout.Version))
+
+	_, err = store.GetWorkoutByID(int64(workout.ID))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	page, err := store.ListWorkouts(WorkoutFilter{UserID: user.ID})
+	require.NoError(t, err)
+	assert.Empty(t, page.Workouts)
+
+	page, err = store.ListWorkouts(WorkoutFilter{UserID: user.ID, Trashed: true})
+	require.NoError(t, err)
+	require.Len(t, page.Workouts, 1)
+	assert.NotNil(t, page.Workouts[0].DeletedAt)
+
+	assert.ErrorIs(t, store.RestoreWorkout(int64(workout.ID), other.ID), ErrNotFound)
+	require.NoError(t, store.RestoreWorkout(int64(workout.ID), user.ID))
+	assert.ErrorIs(t, store.RestoreWorkout(int64(workout.ID), user.ID), ErrNotFound)
+
+	restored, err := store.GetWorkoutByID(int64(workout.ID))
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteWorkout(int64(workout.ID), restored.Version))
+
+	purged, err := store.PurgeDeletedWorkouts(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	purged, err = store.PurgeDeletedWorkouts(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, purged)
+	assert.ErrorIs(t, store.RestoreWorkout(int64(workout.ID), user.ID), ErrNotFound)
+}
+
+func TestValidateWorkout(t *testing.T) {
+	valid := func() *Workout {
+		return &Workout{
+			Title:           "Push day",
+			DurationSeconds: 3600,
+			CaloriesBurned:  400,
+			Visibility:      VisibilityPrivate,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(102.5), OrderIndex: 0},
+				{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(60), OrderIndex: 1},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		mutate     func(w *Workout)
+		wantFields []string
+	}{
+		{name: "valid", mutate: func(w *Workout) {}},
+		{
+			name: "workout fields",
+			mutate: func(w *Workout) {
+				w.Title = " "
+				w.DurationSeconds = -5
+				w.CaloriesBurned = -1
+			},
+			wantFields: []string{"title", "duration_seconds", "calories_burned"},
+		},
+		{
+			name: "unknown visibility",
+			mutate: func(w *Workout) {
+				w.Visibility = "friends"
+			},
+			wantFields: []string{"visibility"},
+		},
+		{
+			name: "session rpe out of range",
+			mutate: func(w *Workout) {
+				w.SessionRPE = FloatPtr(0.5)
+			},
+			wantFields: []string{"session_rpe"},
+		},
+		{
+			name: "rpe out of range",
+			mutate: func(w *Workout) {
+				w.Entries[0].RPE = FloatPtr(11)
+				w.Entries[1].RPE = FloatPtr(7.25)
+			},
+			wantFields: []string{"entries[0].rpe", "entries[1].rpe"},
+		},
+		{
+			name: "reps and duration together",
+			mutate: func(w *Workout) {
+				w.Entries[0].DurationSeconds = IntPtr(30)
+			},
+			wantFields: []string{"entries[0].reps"},
+		},
+		{
+			name: "neither reps nor duration",
+			mutate: func(w *Workout) {
+				w.Entries[1].DurationSeconds = nil
+			},
+			wantFields: []string{"entries[1].reps"},
+		},
+		{
+			name: "weight does not fit DECIMAL(5, 2)",
+			mutate: func(w *Workout) {
+				w.Entries[0].Weight = FloatPtr(1000)
+				w.Entries[1].Weight = FloatPtr(20.125)
+			},
+			wantFields: []string{"entries[0].weight", "entries[1].weight"},
+		},
+		{
+			name: "duplicate order index and missing name",
+			mutate: func(w *Workout) {
+				w.Entries[1].OrderIndex = 0
+				w.Entries[1].ExerciseName = ""
+				w.Entries[1].Sets = 0
+			},
+			wantFields: []string{"entries", "entries[1].exercise_name", "entries[1].sets"},
+		},
+		{
+			name: "exercise id replaces name",
+			mutate: func(w *Workout) {
+				w.Entries[0].ExerciseName = ""
+				w.Entries[0].ExerciseID = IntPtr(1)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workout := valid()
+			tt.mutate(workout)
+
+			v := validator.New()
+			ValidateWorkout(v, workout)
+
+			fields := make([]string, 0, len(v.Errors))
+			for field := range v.Errors {
+				fields = append(fields, field)
+			}
+			assert.ElementsMatch(t, tt.wantFields, fields, v.Errors)
+		})
+	}
+}
+
+func TestWorkoutVisibleTo(t *testing.T) {
+	const owner, follower, stranger, anonymous = 1, 2, 3, 0
+
+	tests := []struct {
+		visibility string
+		viewerID   int
+		following  bool
+		want       bool
+	}{
+		{visibility: VisibilityPrivate, viewerID: owner, want: true},
+		{visibility: VisibilityPrivate, viewerID: follower, following: true, want: false},
+		{visibility: VisibilityPrivate, viewerID: anonymous, want: false},
+		{visibility: VisibilityFollowers, viewerID: owner, want: true},
+		{visibility: VisibilityFollowers, viewerID: follower, following: true, want: true},
+		{visibility: VisibilityFollowers, viewerID: stranger, want: false},
+		{visibility: VisibilityFollowers, viewerID: anonymous, want: false},
+		{visibility: VisibilityPublic, viewerID: stranger, want: true},
+		{visibility: VisibilityPublic, viewerID: anonymous, want: true},
+	}
+
+	for _, tt := range tests {
+		workout := &Workout{UserID: owner, Visibility: tt.visibility}
+		got := workout.VisibleTo(tt.viewerID, tt.following)
+		assert.Equal(t, tt.want, got, "%s workout seen by %d (following=%v)", tt.visibility, tt.viewerID, tt.following)
+	}
+}
+
 func IntPtr(i int) *int {
 	return &i
 }
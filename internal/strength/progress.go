@@ -0,0 +1,59 @@
+package strength
+
+import "time"
+
+// ProgressPoint summarises one session of an exercise for charting.
+type ProgressPoint struct {
+	WorkoutID          int       `json:"workout_id"`
+	Date               time.Time `json:"date"`
+	EstimatedOneRepMax float64   `json:"estimated_1rm"`
+	BestSet            BestSet   `json:"best_set"`
+	Volume             float64   `json:"volume"`
+	SmoothedOneRepMax  *float64  `json:"smoothed_1rm,omitempty"`
+}
+
+// BestSet is the set of a session with the highest estimated one-rep max.
+type BestSet struct {
+	Weight float64 `json:"weight"`
+	Reps   int     `json:"reps"`
+}
+
+// Progress turns sessions, oldest first, into one point each. With a window
+// above 1 every point also carries the moving average of the estimated
+// one-rep max over that many sessions, or fewer at the start of the series.
+func Progress(sessions []Session, formula Formula, window int) []ProgressPoint {
+	points := make([]ProgressPoint, 0, len(sessions))
+	sum := 0.0
+
+	for i, session := range sessions {
+		point := ProgressPoint{WorkoutID: session.WorkoutID, Date: session.PerformedAt}
+
+		for _, set := range session.Sets {
+			point.Volume += float64(set.Sets*set.Reps) * set.Weight
+
+			estimate := formula(set.Weight, set.Reps)
+			better := estimate > point.EstimatedOneRepMax ||
+				estimate == point.EstimatedOneRepMax && set.Weight > point.BestSet.Weight
+			if better {
+				point.EstimatedOneRepMax = estimate
+				point.BestSet = BestSet{Weight: set.Weight, Reps: set.Reps}
+			}
+		}
+
+		point.EstimatedOneRepMax = Round(point.EstimatedOneRepMax, 2)
+		point.Volume = Round(point.Volume, 2)
+
+		if window > 1 {
+			sum += point.EstimatedOneRepMax
+			if i >= window {
+				sum -= points[i-window].EstimatedOneRepMax
+			}
+			smoothed := Round(sum/float64(min(i+1, window)), 2)
+			point.SmoothedOneRepMax = &smoothed
+		}
+
+		points = append(points, point)
+	}
+
+	return points
+}
@@ -0,0 +1,46 @@
+package strength
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	sessions := []Session{
+		{WorkoutID: 1, PerformedAt: day, Sets: []Set{
+			{Sets: 3, Reps: 5, Weight: 90},
+			{Sets: 1, Reps: 1, Weight: 100},
+		}},
+		{WorkoutID: 2, PerformedAt: day.AddDate(0, 0, 3), Sets: []Set{{Sets: 3, Reps: 5, Weight: 95}}},
+		{WorkoutID: 3, PerformedAt: day.AddDate(0, 0, 7), Sets: []Set{{Sets: 1, Reps: 3, Weight: 110}}},
+	}
+
+	points := Progress(sessions, Epley, 0)
+
+	require.Len(t, points, 3)
+	// 90 x 5 estimates 105, above the single at 100
+	assert.Equal(t, ProgressPoint{WorkoutID: 1, Date: day, EstimatedOneRepMax: 105, BestSet: BestSet{Weight: 90, Reps: 5}, Volume: 1450}, points[0])
+	assert.Equal(t, 110.83, points[1].EstimatedOneRepMax)
+	assert.Nil(t, points[1].SmoothedOneRepMax)
+
+	smoothed := Progress(sessions, Epley, 2)
+	assert.Equal(t, 105.0, *smoothed[0].SmoothedOneRepMax)
+	assert.Equal(t, 107.92, *smoothed[1].SmoothedOneRepMax)
+	assert.Equal(t, 115.92, *smoothed[2].SmoothedOneRepMax)
+
+	single := Progress(sessions, Brzycki, 0)
+	assert.Equal(t, BestSet{Weight: 110, Reps: 3}, single[2].BestSet)
+}
+
+func TestFormulaByName(t *testing.T) {
+	formula, ok := FormulaByName(FormulaLombardi)
+	require.True(t, ok)
+	assert.Equal(t, 100.0, formula(100, 1))
+
+	_, ok = FormulaByName("mayhew")
+	assert.False(t, ok)
+}
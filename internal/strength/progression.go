@@ -0,0 +1,203 @@
+package strength
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	RuleLinear            = "linear"
+	RuleDoubleProgression = "double_progression"
+	RuleRPE               = "rpe"
+
+	// MaxWeight is the heaviest weight a workout entry can hold. Targets are
+	// capped at it so a suggestion can always be logged.
+	MaxWeight = 999.99
+)
+
+// Set is one logged entry of an exercise: Sets sets of Reps at Weight. RPE
+// is zero when it was not recorded.
+type Set struct {
+	Sets   int
+	Reps   int
+	Weight float64
+	RPE    float64
+}
+
+// Session holds the sets of one exercise logged in a single workout.
+type Session struct {
+	WorkoutID   int
+	PerformedAt time.Time
+	Sets        []Set
+}
+
+// Target is the suggested load for the next session and why it was chosen.
+type Target struct {
+	Weight float64 `json:"weight"`
+	Reps   int     `json:"reps"`
+	Reason string  `json:"reason"`
+}
+
+// ProgressionRule suggests the next target from an exercise's history,
+// oldest session first. history is never empty.
+type ProgressionRule interface {
+	Next(history []Session) Target
+}
+
+// Linear adds Increment whenever every set at the top weight reached Reps
+// and deloads by DeloadPercent after DeloadAfter sessions in a row that
+// fell short.
+type Linear struct {
+	Reps          int
+	Increment     float64
+	DeloadAfter   int
+	DeloadPercent float64
+}
+
+func (l Linear) Next(history []Session) Target {
+	top := topSet(history[len(history)-1])
+
+	if top.Reps >= l.Reps {
+		return Target{
+			Weight: math.Min(top.Weight+l.Increment, MaxWeight),
+			Reps:   l.Reps,
+			Reason: "all reps were completed last session",
+		}
+	}
+
+	if misses := missedSessions(history, top.Weight, l.Reps); misses >= l.DeloadAfter {
+		return Target{
+			Weight: deload(top.Weight, l.DeloadPercent, l.Increment),
+			Reps:   l.Reps,
+			Reason: fmt.Sprintf("reps were missed %d sessions in a row, deload", misses),
+		}
+	}
+
+	return Target{
+		Weight: top.Weight,
+		Reps:   l.Reps,
+		Reason: "repeat the weight until all reps are completed",
+	}
+}
+
+// DoubleProgression adds reps within MinReps..MaxReps and only adds
+// Increment once every set at the top weight reached MaxReps. Falling below
+// MinReps for DeloadAfter sessions in a row deloads by DeloadPercent.
+type DoubleProgression struct {
+	MinReps       int
+	MaxReps       int
+	Increment     float64
+	DeloadAfter   int
+	DeloadPercent float64
+}
+
+func (d DoubleProgression) Next(history []Session) Target {
+	top := topSet(history[len(history)-1])
+
+	if top.Reps >= d.MaxReps {
+		return Target{
+			Weight: math.Min(top.Weight+d.Increment, MaxWeight),
+			Reps:   d.MinReps,
+			Reason: "the top of the rep range was reached last session",
+		}
+	}
+
+	if top.Reps < d.MinReps {
+		if misses := missedSessions(history, top.Weight, d.MinReps); misses >= d.DeloadAfter {
+			return Target{
+				Weight: deload(top.Weight, d.DeloadPercent, d.Increment),
+				Reps:   d.MinReps,
+				Reason: fmt.Sprintf("the rep range was missed %d sessions in a row, deload", misses),
+			}
+		}
+
+		return Target{
+			Weight: top.Weight,
+			Reps:   d.MinReps,
+			Reason: "repeat the weight until the bottom of the rep range is reached",
+		}
+	}
+
+	return Target{
+		Weight: top.Weight,
+		Reps:   top.Reps + 1,
+		Reason: "add a rep before adding weight",
+	}
+}
+
+// RPEBased estimates a one-rep max from the last top set and its RPE and
+// picks the weight expected to land Reps at TargetRPE. Each point of RPE
+// below 10 counts as one rep left in reserve.
+type RPEBased struct {
+	Reps      int
+	TargetRPE float64
+	Increment float64
+}
+
+func (r RPEBased) Next(history []Session) Target {
+	top := topSet(history[len(history)-1])
+
+	if top.RPE == 0 {
+		return Target{
+			Weight: top.Weight,
+			Reps:   r.Reps,
+			Reason: "no RPE was logged last session, repeat the weight",
+		}
+	}
+
+	// Epley with the reps in reserve counted as performed reps
+	oneRepMax := top.Weight * (1 + (float64(top.Reps)+10-top.RPE)/30)
+	weight := oneRepMax / (1 + (float64(r.Reps)+10-r.TargetRPE)/30)
+
+	return Target{
+		Weight: math.Min(RoundTo(weight, r.Increment), MaxWeight),
+		Reps:   r.Reps,
+		Reason: fmt.Sprintf("%d reps at RPE %g last session", top.Reps, top.RPE),
+	}
+}
+
+// RoundTo rounds value to the nearest multiple of increment.
+func RoundTo(value, increment float64) float64 {
+	if increment <= 0 {
+		return value
+	}
+
+	return Round(math.Round(value/increment)*increment, 2)
+}
+
+// topSet returns the heaviest weight of the session with the fewest reps and
+// the highest RPE logged at it, the set that decides progression.
+func topSet(session Session) Set {
+	var top Set
+	for _, set := range session.Sets {
+		switch {
+		case set.Weight > top.Weight || top.Sets == 0:
+			top = set
+		case set.Weight == top.Weight:
+			top.Reps = min(top.Reps, set.Reps)
+			top.RPE = max(top.RPE, set.RPE)
+		}
+	}
+
+	return top
+}
+
+// missedSessions counts the sessions at the end of history whose top set was
+// at weight or above but fell short of reps.
+func missedSessions(history []Session, weight float64, reps int) int {
+	misses := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		top := topSet(history[i])
+		if top.Weight < weight || top.Reps >= reps {
+			break
+		}
+		misses++
+	}
+
+	return misses
+}
+
+func deload(weight, percent, increment float64) float64 {
+	return RoundTo(weight*(1-percent/100), increment)
+}
@@ -0,0 +1,125 @@
+package strength
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sessions(sets ...Set) []Session {
+	history := make([]Session, 0, len(sets))
+	for _, set := range sets {
+		history = append(history, Session{Sets: []Set{set}})
+	}
+
+	return history
+}
+
+func TestLinear(t *testing.T) {
+	rule := Linear{Reps: 5, Increment: 2.5, DeloadAfter: 3, DeloadPercent: 10}
+
+	tests := []struct {
+		name    string
+		history []Session
+		want    Target
+	}{
+		{
+			name:    "all reps hit",
+			history: sessions(Set{Sets: 3, Reps: 5, Weight: 100}),
+			want:    Target{Weight: 102.5, Reps: 5, Reason: "all reps were completed last session"},
+		},
+		{
+			name: "one short set at the top weight",
+			history: []Session{{Sets: []Set{
+				{Sets: 2, Reps: 5, Weight: 100},
+				{Sets: 1, Reps: 4, Weight: 100},
+				{Sets: 1, Reps: 10, Weight: 60},
+			}}},
+			want: Target{Weight: 100, Reps: 5, Reason: "repeat the weight until all reps are completed"},
+		},
+		{
+			name: "repeated failures deload",
+			history: sessions(
+				Set{Sets: 3, Reps: 5, Weight: 97.5},
+				Set{Sets: 3, Reps: 4, Weight: 100},
+				Set{Sets: 3, Reps: 4, Weight: 100},
+				Set{Sets: 3, Reps: 3, Weight: 100},
+			),
+			want: Target{Weight: 90, Reps: 5, Reason: "reps were missed 3 sessions in a row, deload"},
+		},
+		{
+			name:    "capped at the heaviest storable weight",
+			history: sessions(Set{Sets: 1, Reps: 5, Weight: 998.5}),
+			want:    Target{Weight: MaxWeight, Reps: 5, Reason: "all reps were completed last session"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rule.Next(tt.history))
+		})
+	}
+}
+
+func TestDoubleProgression(t *testing.T) {
+	rule := DoubleProgression{MinReps: 8, MaxReps: 12, Increment: 2.5, DeloadAfter: 2, DeloadPercent: 10}
+
+	tests := []struct {
+		name    string
+		history []Session
+		want    Target
+	}{
+		{
+			name:    "inside the range",
+			history: sessions(Set{Sets: 3, Reps: 9, Weight: 40}),
+			want:    Target{Weight: 40, Reps: 10, Reason: "add a rep before adding weight"},
+		},
+		{
+			name:    "top of the range",
+			history: sessions(Set{Sets: 3, Reps: 12, Weight: 40}),
+			want:    Target{Weight: 42.5, Reps: 8, Reason: "the top of the rep range was reached last session"},
+		},
+		{
+			name:    "below the range once",
+			history: sessions(Set{Sets: 3, Reps: 12, Weight: 40}, Set{Sets: 3, Reps: 6, Weight: 42.5}),
+			want:    Target{Weight: 42.5, Reps: 8, Reason: "repeat the weight until the bottom of the rep range is reached"},
+		},
+		{
+			name:    "below the range twice",
+			history: sessions(Set{Sets: 3, Reps: 7, Weight: 42.5}, Set{Sets: 3, Reps: 6, Weight: 42.5}),
+			want:    Target{Weight: 37.5, Reps: 8, Reason: "the rep range was missed 2 sessions in a row, deload"},
+		},
+		{
+			name:    "capped at the heaviest storable weight",
+			history: sessions(Set{Sets: 3, Reps: 12, Weight: MaxWeight}),
+			want:    Target{Weight: MaxWeight, Reps: 8, Reason: "the top of the rep range was reached last session"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rule.Next(tt.history))
+		})
+	}
+}
+
+func TestRPEBased(t *testing.T) {
+	rule := RPEBased{Reps: 5, TargetRPE: 8, Increment: 2.5}
+
+	// 5 reps at RPE 7 is 8 reps to failure, an estimated max of 126.67;
+	// 5 reps at RPE 8 is 7 reps to failure, 126.67 / (1 + 7/30) = 102.7
+	got := rule.Next(sessions(Set{Sets: 3, Reps: 5, Weight: 100, RPE: 7}))
+	assert.Equal(t, Target{Weight: 102.5, Reps: 5, Reason: "5 reps at RPE 7 last session"}, got)
+
+	got = rule.Next(sessions(Set{Sets: 3, Reps: 5, Weight: 100}))
+	assert.Equal(t, 100.0, got.Weight)
+
+	got = rule.Next(sessions(Set{Sets: 1, Reps: 5, Weight: 990, RPE: 6}))
+	assert.Equal(t, MaxWeight, got.Weight)
+}
+
+func TestRoundTo(t *testing.T) {
+	assert.Equal(t, 122.5, RoundTo(121.55, 2.5))
+	assert.Equal(t, 121.55, RoundTo(121.55, 0))
+	assert.Equal(t, 0.75, RoundTo(0.8, 0.25))
+}
@@ -0,0 +1,64 @@
+package strength
+
+import "math"
+
+// Epley estimates a one-rep max as weight * (1 + reps/30). A single rep is
+// returned as-is so a true max is never inflated.
+func Epley(weight float64, reps int) float64 {
+	if reps <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return weight
+	}
+
+	return weight * (1 + float64(reps)/30)
+}
+
+// Brzycki estimates a one-rep max as weight * 36 / (37 - reps). The formula
+// breaks down at 37 reps and beyond, so those sets yield zero.
+func Brzycki(weight float64, reps int) float64 {
+	if reps <= 0 || reps >= 37 {
+		return 0
+	}
+
+	return weight * 36 / float64(37-reps)
+}
+
+// Lombardi estimates a one-rep max as weight * reps^0.10. It credits high
+// rep sets less than Epley does.
+func Lombardi(weight float64, reps int) float64 {
+	if reps <= 0 {
+		return 0
+	}
+
+	return weight * math.Pow(float64(reps), 0.10)
+}
+
+const (
+	FormulaEpley    = "epley"
+	FormulaBrzycki  = "brzycki"
+	FormulaLombardi = "lombardi"
+)
+
+// Formula estimates a one-rep max from a set of reps at weight.
+type Formula func(weight float64, reps int) float64
+
+// FormulaByName looks up one of the Formula* names.
+func FormulaByName(name string) (Formula, bool) {
+	switch name {
+	case FormulaEpley:
+		return Epley, true
+	case FormulaBrzycki:
+		return Brzycki, true
+	case FormulaLombardi:
+		return Lombardi, true
+	default:
+		return nil, false
+	}
+}
+
+func Round(value float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,32 @@
+package strength
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOneRepMaxFormulas(t *testing.T) {
+	tests := []struct {
+		name     string
+		weight   float64
+		reps     int
+		epley    float64
+		brzycki  float64
+		lombardi float64
+	}{
+		{name: "single rep", weight: 100, reps: 1, epley: 100, brzycki: 100, lombardi: 100},
+		{name: "five reps", weight: 100, reps: 5, epley: 116.67, brzycki: 112.5, lombardi: 117.46},
+		{name: "ten reps", weight: 80, reps: 10, epley: 106.67, brzycki: 106.67, lombardi: 100.71},
+		{name: "no reps", weight: 100, reps: 0, epley: 0, brzycki: 0, lombardi: 0},
+		{name: "beyond brzycki range", weight: 20, reps: 40, epley: 46.67, brzycki: 0, lombardi: 28.92},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.epley, Round(Epley(tt.weight, tt.reps), 2))
+			assert.Equal(t, tt.brzycki, Round(Brzycki(tt.weight, tt.reps), 2))
+			assert.Equal(t, tt.lombardi, Round(Lombardi(tt.weight, tt.reps), 2))
+		})
+	}
+}
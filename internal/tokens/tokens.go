@@ -8,7 +8,10 @@ import (
 )
 
 const (
-	ScopeAuth = "authentication"
+	ScopeAuth          = "authentication"
+	ScopeRefresh       = "refresh"
+	ScopePasswordReset = "password-reset"
+	ScopeActivation    = "activation"
 )
 
 type Token struct {
@@ -17,6 +20,23 @@ type Token struct {
 	UserId    int       `json:"-"`
 	Expiry    time.Time `json:"Expiry"`
 	Scope     string    `json:"-"`
+	FamilyID  string    `json:"-"`
+	Client    Client    `json:"-"`
+}
+
+// Client describes the device a token was issued to, shown when listing
+// active sessions.
+type Client struct {
+	UserAgent string
+	IPAddress string
+}
+
+// TokenPair is an access token together with the refresh token that can
+// replace it. Both belong to the same family so a detected refresh token
+// reuse can revoke every token descending from the original sign-in.
+type TokenPair struct {
+	Access  *Token `json:"auth_token"`
+	Refresh *Token `json:"refresh_token"`
 }
 
 func GenerateToken(userID int, ttl time.Duration, scope string) (*Token, error) {
@@ -26,16 +46,58 @@ func GenerateToken(userID int, ttl time.Duration, scope string) (*Token, error)
 		Scope:  scope,
 	}
 
-	emptyBytes := make([]byte, 32)
-
-	_, err := rand.Read(emptyBytes)
+	plainText, err := randomString(32)
 	if err != nil {
 		return nil, err
 	}
 
-	token.PlainText = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(emptyBytes)
+	token.PlainText = plainText
 	hash := sha256.Sum256([]byte(token.PlainText))
 	token.Hash = hash[:]
 
 	return token, nil
 }
+
+func GenerateTokenPair(userID int, accessTTL, refreshTTL time.Duration, familyID string, client Client) (*TokenPair, error) {
+	if familyID == "" {
+		var err error
+		familyID, err = randomString(16)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	access, err := GenerateToken(userID, accessTTL, ScopeAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := GenerateToken(userID, refreshTTL, ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	access.FamilyID = familyID
+	access.Client = client
+	refresh.FamilyID = familyID
+	refresh.Client = client
+
+	return &TokenPair{Access: access, Refresh: refresh}, nil
+}
+
+// GenerateShareCode returns an unguessable code for links that grant read
+// access to a single resource without signing in.
+func GenerateShareCode() (string, error) {
+	return randomString(16)
+}
+
+func randomString(size int) (string, error) {
+	emptyBytes := make([]byte, size)
+
+	_, err := rand.Read(emptyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(emptyBytes), nil
+}
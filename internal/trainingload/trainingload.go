@@ -0,0 +1,131 @@
+package trainingload
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	AcuteDays   = 7
+	ChronicDays = 28
+
+	FlagLow  = "low"
+	FlagHigh = "high"
+)
+
+// Day is the summed session load of one UTC calendar day. Following
+// Foster's session RPE method a session's load is its duration in minutes
+// times how hard it felt on a 1-10 scale.
+type Day struct {
+	Date time.Time
+	Load float64
+}
+
+// Thresholds is the acute:chronic workload ratio band considered safe.
+type Thresholds struct {
+	Low  float64 `json:"acwr_low"`
+	High float64 `json:"acwr_high"`
+}
+
+// Point describes the load situation at the end of one day. Ratios that
+// cannot be computed, like the ACWR without any chronic load, are nil.
+type Point struct {
+	Date     time.Time `json:"date"`
+	Load     float64   `json:"load"`
+	Acute    float64   `json:"acute"`
+	Chronic  float64   `json:"chronic"`
+	ACWR     *float64  `json:"acwr"`
+	Monotony *float64  `json:"monotony"`
+	Strain   *float64  `json:"strain"`
+	Flag     string    `json:"flag,omitempty"`
+}
+
+// Compute returns one point per day from from to to, both inclusive. days
+// must cover the ChronicDays-1 days before from as well; days without an
+// entry count as rest days.
+//
+// Acute load is the sum of the last 7 days and chronic load the weekly
+// average of the last 28, so a steady routine has an ACWR of 1. Monotony is
+// the mean daily load of the last 7 days over its standard deviation and
+// strain is acute load times monotony.
+func Compute(days []Day, from, to time.Time, thresholds Thresholds) []Point {
+	from = truncateDay(from)
+	to = truncateDay(to)
+	start := from.AddDate(0, 0, -(ChronicDays - 1))
+
+	loads := map[time.Time]float64{}
+	for _, day := range days {
+		loads[truncateDay(day.Date)] += day.Load
+	}
+
+	window := []float64{}
+	for date := start; date.Before(from); date = date.AddDate(0, 0, 1) {
+		window = append(window, loads[date])
+	}
+
+	points := []Point{}
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		window = append(window, loads[date])
+		if len(window) > ChronicDays {
+			window = window[1:]
+		}
+		points = append(points, point(date, window, thresholds))
+	}
+
+	return points
+}
+
+// point computes the metrics of date from the ChronicDays loads ending on it.
+func point(date time.Time, window []float64, thresholds Thresholds) Point {
+	week := window[len(window)-AcuteDays:]
+
+	p := Point{Date: date, Load: round(window[len(window)-1])}
+	p.Acute = round(sum(week))
+	p.Chronic = round(sum(window) / (ChronicDays / AcuteDays))
+
+	if p.Chronic > 0 {
+		acwr := round(p.Acute / p.Chronic)
+		p.ACWR = &acwr
+
+		switch {
+		case acwr > thresholds.High:
+			p.Flag = FlagHigh
+		case acwr < thresholds.Low:
+			p.Flag = FlagLow
+		}
+	}
+
+	mean := sum(week) / AcuteDays
+	variance := 0.0
+	for _, load := range week {
+		variance += (load - mean) * (load - mean)
+	}
+	deviation := math.Sqrt(variance / AcuteDays)
+
+	if deviation > 0 {
+		monotony := round(mean / deviation)
+		strain := round(sum(week) * mean / deviation)
+		p.Monotony = &monotony
+		p.Strain = &strain
+	}
+
+	return p
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+
+	return total
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
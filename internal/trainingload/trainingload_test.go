@@ -0,0 +1,87 @@
+package trainingload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var thresholds = Thresholds{Low: 0.8, High: 1.5}
+
+// daily builds one day per load, the last one falling on end.
+func daily(end time.Time, loads ...float64) []Day {
+	days := make([]Day, 0, len(loads))
+	for i, load := range loads {
+		days = append(days, Day{Date: end.AddDate(0, 0, i-len(loads)+1), Load: load})
+	}
+
+	return days
+}
+
+func repeat(load float64, n int) []float64 {
+	loads := make([]float64, n)
+	for i := range loads {
+		loads[i] = load
+	}
+
+	return loads
+}
+
+func TestComputeSteadyLoad(t *testing.T) {
+	day := time.Date(2026, 3, 28, 0, 0, 0, 0, time.UTC)
+
+	points := Compute(daily(day, repeat(100, ChronicDays)...), day, day, thresholds)
+
+	require.Len(t, points, 1)
+	assert.Equal(t, 700.0, points[0].Acute)
+	assert.Equal(t, 700.0, points[0].Chronic)
+	assert.Equal(t, 1.0, *points[0].ACWR)
+	assert.Empty(t, points[0].Flag)
+	// identical days have no spread, so monotony is undefined
+	assert.Nil(t, points[0].Monotony)
+}
+
+func TestComputeFlags(t *testing.T) {
+	day := time.Date(2026, 3, 28, 0, 0, 0, 0, time.UTC)
+
+	// three easy weeks followed by a hard one
+	spike := append(repeat(100, 21), repeat(300, 7)...)
+	points := Compute(daily(day, spike...), day, day, thresholds)
+	assert.Equal(t, 2100.0, points[0].Acute)
+	assert.Equal(t, 1050.0, points[0].Chronic)
+	assert.Equal(t, 2.0, *points[0].ACWR)
+	assert.Equal(t, FlagHigh, points[0].Flag)
+
+	// a week off after three normal weeks
+	rest := append(repeat(100, 21), repeat(0, 7)...)
+	points = Compute(daily(day, rest...), day, day, thresholds)
+	assert.Equal(t, 0.0, *points[0].ACWR)
+	assert.Equal(t, FlagLow, points[0].Flag)
+
+	points = Compute(nil, day, day, thresholds)
+	assert.Nil(t, points[0].ACWR)
+	assert.Empty(t, points[0].Flag)
+}
+
+func TestComputeMonotonyAndSeries(t *testing.T) {
+	from := time.Date(2026, 3, 26, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+
+	// every other day for the last week ending on from
+	days := daily(from, 100, 0, 100, 0, 100, 0, 100)
+	days = append(days, Day{Date: to.Add(18 * time.Hour), Load: 50})
+
+	points := Compute(days, from.Add(5*time.Hour), to, thresholds)
+
+	require.Len(t, points, 3)
+	assert.Equal(t, from, points[0].Date)
+	// mean 57.14 over a standard deviation of 49.49
+	assert.Equal(t, 1.15, *points[0].Monotony)
+	assert.Equal(t, 461.88, *points[0].Strain)
+	assert.Equal(t, 0.0, points[1].Load)
+	assert.Equal(t, to, points[2].Date)
+	assert.Equal(t, 50.0, points[2].Load)
+	assert.Equal(t, 350.0, points[2].Acute)
+}
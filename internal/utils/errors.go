@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Stable, machine-readable problem codes. Clients should branch on these
+// rather than on titles or details, which are meant for humans.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeMalformedBody        = "malformed_body"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeInvalidID            = "invalid_id"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeInvalidToken         = "invalid_token"
+	CodeForbidden            = "forbidden"
+	CodeInactiveAccount      = "inactive_account"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeEditConflict         = "edit_conflict"
+	CodePreconditionRequired = "precondition_required"
+	CodeInternal             = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details object. Code and Errors are
+// extension members: Errors maps request fields to what is wrong with them.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Code     string            `json:"code"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+func WriteProblem(w http.ResponseWriter, r *http.Request, problem Problem) error {
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Instance == "" && r != nil {
+		problem.Instance = r.URL.Path
+	}
+
+	js, err := json.MarshalIndent(problem, "", " ")
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	w.Write(js)
+
+	return nil
+}
+
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code string, detail string) error {
+	return WriteProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+func BadRequest(w http.ResponseWriter, r *http.Request, detail string) error {
+	return WriteError(w, r, http.StatusBadRequest, CodeBadRequest, detail)
+}
+
+// MalformedBody reports an error returned by ReadJSON.
+func MalformedBody(w http.ResponseWriter, r *http.Request, err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		detail := fmt.Sprintf("body must not be larger than %d bytes", maxBytesError.Limit)
+		return WriteError(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, detail)
+	}
+
+	return WriteError(w, r, http.StatusBadRequest, CodeMalformedBody, err.Error())
+}
+
+func InvalidID(w http.ResponseWriter, r *http.Request) error {
+	return WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "the id in the URL must be a positive integer")
+}
+
+func NotFound(w http.ResponseWriter, r *http.Request, detail string) error {
+	return WriteError(w, r, http.StatusNotFound, CodeNotFound, detail)
+}
+
+func Forbidden(w http.ResponseWriter, r *http.Request, detail string) error {
+	return WriteError(w, r, http.StatusForbidden, CodeForbidden, detail)
+}
+
+// ServerError hides the cause from the client; callers log it first.
+func ServerError(w http.ResponseWriter, r *http.Request) error {
+	return WriteError(w, r, http.StatusInternalServerError, CodeInternal, "the server encountered a problem and could not process the request")
+}
+
+func FailedValidation(w http.ResponseWriter, r *http.Request, fieldErrors map[string]string) error {
+	return WriteProblem(w, r, Problem{
+		Status: http.StatusUnprocessableEntity,
+		Code:   CodeValidationFailed,
+		Detail: "the request contains invalid fields",
+		Errors: fieldErrors,
+	})
+}
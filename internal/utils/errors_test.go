@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailedValidation(t *testing.T) {
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/workouts", nil)
+
+	require.NoError(t, FailedValidation(rr, r, map[string]string{"title": "is required"}))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+	var problem Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+	assert.Equal(t, Problem{
+		Type:     "about:blank",
+		Title:    "Unprocessable Entity",
+		Status:   http.StatusUnprocessableEntity,
+		Code:     CodeValidationFailed,
+		Detail:   "the request contains invalid fields",
+		Instance: "/workouts",
+		Errors:   map[string]string{"title": "is required"},
+	}, problem)
+}
+
+func TestServerErrorHidesDetails(t *testing.T) {
+	rr := httptest.NewRecorder()
+	require.NoError(t, ServerError(rr, httptest.NewRequest(http.MethodGet, "/workouts", nil)))
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	assert.Equal(t, CodeInternal, body["code"])
+	assert.NotContains(t, body, "errors")
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag formats a resource version as a strong entity tag.
+func ETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// IfMatchVersion reads the version from the If-Match header. ok is false when
+// the header is missing. Anything other than a single tag produced by ETag,
+// including "*", yields version 0, which never matches a stored version.
+func IfMatchVersion(r *http.Request) (version int, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version < 1 {
+		return 0, true
+	}
+
+	return version, true
+}
+
+// NotModified reports whether If-None-Match lists etag or "*". Tags are
+// compared weakly, as RFC 9110 requires for If-None-Match.
+func NotModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
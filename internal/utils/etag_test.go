@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header      string
+		wantVersion int
+		wantOK      bool
+	}{
+		{header: "", wantVersion: 0, wantOK: false},
+		{header: `"3"`, wantVersion: 3, wantOK: true},
+		{header: `W/"3"`, wantVersion: 3, wantOK: true},
+		{header: `*`, wantVersion: 0, wantOK: true},
+		{header: `"3", "4"`, wantVersion: 0, wantOK: true},
+		{header: `"abc"`, wantVersion: 0, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/workout", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+
+		version, ok := IfMatchVersion(r)
+		assert.Equal(t, tt.wantVersion, version, tt.header)
+		assert.Equal(t, tt.wantOK, ok, tt.header)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: `"2"`, want: true},
+		{header: `W/"2"`, want: true},
+		{header: `"1", "2"`, want: true},
+		{header: `"1"`, want: false},
+		{header: `*`, want: true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/workout/1", nil)
+		if tt.header != "" {
+			r.Header.Set("If-None-Match", tt.header)
+		}
+
+		assert.Equal(t, tt.want, NotModified(r, ETag(2)), tt.header)
+	}
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const MaxRequestBodyBytes = 1 << 20
+
+// ReadJSON decodes a single JSON value from the request body into dst. It
+// rejects unknown fields and bodies over MaxRequestBodyBytes, and its errors
+// are phrased so they can be shown to the client as is.
+func ReadJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		return describeDecodeError(err)
+	}
+
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// ApplyMergePatch applies an RFC 7396 JSON merge patch to original and
+// decodes the result into dst. Members set to null in the patch are removed,
+// so dst should be a zero value rather than a copy of original.
+func ApplyMergePatch(original any, patch json.RawMessage, dst any) error {
+	var patchValue any
+	err := json.Unmarshal(patch, &patchValue)
+	if err != nil {
+		return describeDecodeError(err)
+	}
+	if _, ok := patchValue.(map[string]any); !ok {
+		return errors.New("body must be a JSON object")
+	}
+
+	js, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+
+	var target any
+	err = json.Unmarshal(js, &target)
+	if err != nil {
+		return err
+	}
+
+	js, err = json.Marshal(mergePatch(target, patchValue))
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(dst)
+	if err != nil {
+		return describeDecodeError(err)
+	}
+
+	return nil
+}
+
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+func describeDecodeError(err error) error {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("body contains badly-formed JSON")
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+		}
+		return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+	case errors.Is(err, io.EOF):
+		return errors.New("body must not be empty")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return fmt.Errorf("body contains unknown field %s", fieldName)
+	default:
+		return err
+	}
+}
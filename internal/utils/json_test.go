@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadJSON(t *testing.T) {
+	type payload struct {
+		Title string `json:"title"`
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "valid", body: `{"title": "Push day"}`},
+		{name: "unknown field", body: `{"title": "Push day", "owner": 1}`, wantErr: `body contains unknown field "owner"`},
+		{name: "wrong type", body: `{"title": 5}`, wantErr: `incorrect JSON type for field "title"`},
+		{name: "badly formed", body: `{"title": }`, wantErr: "badly-formed JSON"},
+		{name: "empty", body: ``, wantErr: "body must not be empty"},
+		{name: "two values", body: `{"title": "a"}{"title": "b"}`, wantErr: "single JSON value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/workouts", strings.NewReader(tt.body))
+			var dst payload
+
+			err := ReadJSON(httptest.NewRecorder(), r, &dst)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "Push day", dst.Title)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestReadJSONRejectsOversizedBody(t *testing.T) {
+	body := `{"title": "` + strings.Repeat("a", MaxRequestBodyBytes) + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/workouts", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	var dst struct {
+		Title string `json:"title"`
+	}
+	err := ReadJSON(rr, r, &dst)
+
+	var maxBytesError *http.MaxBytesError
+	require.True(t, errors.As(err, &maxBytesError))
+
+	MalformedBody(rr, r, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	type entry struct {
+		ID   int `json:"id"`
+		Reps int `json:"reps"`
+	}
+	type workout struct {
+		Title       string  `json:"title"`
+		Description string  `json:"description"`
+		Calories    *int    `json:"calories"`
+		Entries     []entry `json:"entries"`
+	}
+
+	calories := 300
+	original := workout{
+		Title:       "Push day",
+		Description: "chest",
+		Calories:    &calories,
+		Entries:     []entry{{ID: 1, Reps: 8}, {ID: 2, Reps: 10}},
+	}
+
+	var patched workout
+	err := ApplyMergePatch(original, []byte(`{"title": "Push day B", "calories": null, "entries": [{"id": 2, "reps": 12}]}`), &patched)
+	require.NoError(t, err)
+	assert.Equal(t, workout{
+		Title:       "Push day B",
+		Description: "chest",
+		Entries:     []entry{{ID: 2, Reps: 12}},
+	}, patched)
+
+	var rejected workout
+	err = ApplyMergePatch(original, []byte(`{"owner": 3}`), &rejected)
+	assert.EqualError(t, err, `body contains unknown field "owner"`)
+
+	err = ApplyMergePatch(original, []byte(`[]`), &rejected)
+	assert.EqualError(t, err, "body must be a JSON object")
+}
@@ -3,8 +3,11 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -26,14 +29,77 @@ func WriterJSON(w http.ResponseWriter, status int, data Envelope) error {
 }
 
 func ReadID(r *http.Request) (int64, error) {
-	idParam := chi.URLParam(r, "id")
+	return ReadIDParam(r, "id")
+}
+
+func ReadIDParam(r *http.Request, name string) (int64, error) {
+	idParam := chi.URLParam(r, name)
 	if idParam == "" {
-		return 0, errors.New("id param seems to be empty " + idParam)
+		return 0, errors.New(name + " param seems to be empty")
 	}
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
 		return 0, err
 	}
+	if id < 1 {
+		return 0, errors.New(name + " param must be a positive integer")
+	}
 
 	return id, nil
 }
+
+func ReadQueryInt(r *http.Request, key string) (*int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", key)
+	}
+
+	return &i, nil
+}
+
+func ReadQueryFloat(r *http.Request, key string) (*float64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", key)
+	}
+
+	return &f, nil
+}
+
+func ReadQueryTime(r *http.Request, key string) (*time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return &t, nil
+	}
+
+	t, err = time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a RFC3339 timestamp or YYYY-MM-DD date", key)
+	}
+
+	return &t, nil
+}
+
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
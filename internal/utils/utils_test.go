@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadIDParam(t *testing.T) {
+	tests := []struct {
+		param   string
+		wantID  int64
+		wantErr bool
+	}{
+		{param: "42", wantID: 42},
+		{param: "", wantErr: true},
+		{param: "abc", wantErr: true},
+		{param: "0", wantErr: true},
+		{param: "-3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("id", tt.param)
+		r := httptest.NewRequest(http.MethodGet, "/workout/"+tt.param, nil)
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeContext))
+
+		id, err := ReadIDParam(r, "id")
+		assert.Equal(t, tt.wantErr, err != nil, tt.param)
+		assert.Equal(t, tt.wantID, id, tt.param)
+	}
+}
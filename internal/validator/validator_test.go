@@ -0,0 +1,43 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatorKeepsFirstErrorPerKey(t *testing.T) {
+	v := New()
+	assert.True(t, v.Valid())
+
+	v.Check(false, "title", "is required")
+	v.Check(false, "title", "cannot exceed 255 characters")
+	v.Check(true, "duration_seconds", "must be positive")
+
+	assert.False(t, v.Valid())
+	assert.Equal(t, map[string]string{"title": "is required"}, v.Errors)
+}
+
+func TestMaxDecimalPlaces(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  bool
+	}{
+		{value: 100, want: true},
+		{value: 102.5, want: true},
+		{value: 0.1 + 0.2, want: true},
+		{value: 2.25, want: true},
+		{value: 999.99, want: true},
+		{value: 12.345, want: false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MaxDecimalPlaces(tt.value, 2), "%v", tt.value)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	assert.True(t, Unique([]int{0, 1, 2}))
+	assert.False(t, Unique([]int{0, 1, 0}))
+	assert.True(t, Unique([]int{}))
+}
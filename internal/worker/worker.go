@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Group runs background tasks and lets shutdown wait for them. Long running
+// tasks should return once the context passed to them is cancelled.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	logger *slog.Logger
+}
+
+func NewGroup(logger *slog.Logger) *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{
+		ctx:    ctx,
+		cancel: cancel,
+		logger: logger,
+	}
+}
+
+func (g *Group) Go(name string, task func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if rec := recover(); rec != nil {
+				g.logger.Error("panic in background task", "task", name, "panic", rec)
+			}
+		}()
+
+		task(g.ctx)
+	}()
+}
+
+// Shutdown cancels the tasks' context and waits for them to return, giving
+// up when ctx is done.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Every runs task once straight away and then every interval until the
+// group shuts down. Errors are logged and do not stop the schedule.
+func (g *Group) Every(name string, interval time.Duration, task func(ctx context.Context) error) {
+	g.Go(name, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := task(ctx); err != nil && ctx.Err() == nil {
+				g.logger.Error("background task failed", "task", name, "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
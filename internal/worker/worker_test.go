@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEveryRepeatsUntilShutdown(t *testing.T) {
+	group := NewGroup(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var runs atomic.Int32
+	group.Every("tick", time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return errors.New("keeps going")
+	})
+
+	require.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, group.Shutdown(ctx))
+
+	stopped := runs.Load()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load())
+}
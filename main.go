@@ -1,39 +1,86 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
 	"fmt"
 	"go-server/internal/app"
+	"go-server/internal/config"
 	"go-server/internal/routes"
+	"net"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	var port int
-	flag.IntVar(&port, "port", 8080, "go backend server port")
-	flag.Parse()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	app, err := app.NewApplication()
+	app, err := app.NewApplication(cfg)
 	if err != nil {
 		panic(err)
 	}
-	defer app.DB.Close()
 
 	routes := routes.SetupRoutes(app)
 
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler:      routes,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		app.Logger.Error("cannot listen", "addr", server.Addr, "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.Server.AdminPort != 0 {
+		adminServer := &http.Server{
+			Addr:         fmt.Sprintf(":%d", cfg.Server.AdminPort),
+			Handler:      adminRoutes(app),
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+		}
+		go func() {
+			err := adminServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				app.Logger.Error("admin server error", "addr", adminServer.Addr, "error", err)
+			}
+		}()
+		defer adminServer.Close()
+		app.Logger.Info("admin server is running", "port", cfg.Server.AdminPort)
 	}
 
-	app.Logger.Printf("Server is running on port %d\n", port)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	err = server.ListenAndServe()
+	app.Logger.Info("server is running", "port", cfg.Server.Port)
+
+	err = app.Serve(ctx, server, listener)
 	if err != nil {
-		app.Logger.Fatal(err)
+		app.Logger.Error("server error", "error", err)
+	}
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if closeErr := app.Close(closeCtx); closeErr != nil || err != nil {
+		os.Exit(1)
 	}
 }
+
+// adminRoutes are kept off the public listener so metrics are only
+// reachable from inside the deployment.
+func adminRoutes(application *app.Application) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", application.Metrics.Handler())
+	return mux
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-server/internal/logging"
+	"go-server/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogIncludesRequestAndUser(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, "info")
+
+	router := chi.NewRouter()
+	router.Use(RequestID)
+	router.Use(AccessLog(logger))
+	router.Get("/workout/{id}", func(w http.ResponseWriter, r *http.Request) {
+		SetUser(r, &store.User{ID: 42})
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/workout/7", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, "abc-123", rr.Header().Get(RequestIDHeader))
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "abc-123", line["request_id"])
+	assert.Equal(t, "GET", line["method"])
+	assert.Equal(t, "/workout/{id}", line["route"])
+	assert.Equal(t, float64(http.StatusTeapot), line["status"])
+	assert.Equal(t, float64(42), line["user_id"])
+}
+
+func TestRequestIDGeneratedWhenMissing(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, logging.RequestID(r.Context()))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Len(t, rr.Header().Get(RequestIDHeader), 32)
+}
@@ -2,22 +2,35 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"go-server/internal/metrics"
 	"go-server/internal/store"
 	"go-server/internal/tokens"
 	"go-server/internal/utils"
+	"log/slog"
 	"net/http"
 	"strings"
 )
 
 type UserMiddleware struct {
-	UserStore store.UserStore
+	UserStore  store.UserStore
+	TokenStore store.TokenStore
+	Metrics    *metrics.Metrics
+	Logger     *slog.Logger
 }
 
 type contextKey string
 
-const UserContextKey = contextKey("user")
+const (
+	UserContextKey  = contextKey("user")
+	TokenContextKey = contextKey("token")
+)
 
 func SetUser(r *http.Request, user *store.User) *http.Request {
+	if entry, ok := r.Context().Value(accessLogContextKey).(*accessLogEntry); ok && !user.IsAnonymous() {
+		entry.userID = user.ID
+	}
+
 	ctx := context.WithValue(r.Context(), UserContextKey, user)
 	return r.WithContext(ctx)
 }
@@ -31,6 +44,18 @@ func GetUser(r *http.Request) *store.User {
 	return user
 }
 
+func SetToken(r *http.Request, token string) *http.Request {
+	ctx := context.WithValue(r.Context(), TokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// GetToken returns the bearer token the request was authenticated with, or an
+// empty string for anonymous requests.
+func GetToken(r *http.Request) string {
+	token, _ := r.Context().Value(TokenContextKey).(string)
+	return token
+}
+
 func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
@@ -44,23 +69,30 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 
 		headerParts := strings.Split(authHeader, " ") // Bearer <TOKEN>
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			utils.WriterJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid authorization header"})
+			um.Metrics.AuthFailure("malformed_header")
+			invalidAuthentication(w, r, "authorization header must be of the form: Bearer <token>")
 			return
 		}
 
 		token := headerParts[1]
 		user, err := um.UserStore.GetUserToken(tokens.ScopeAuth, token)
-		if err != nil {
-			utils.WriterJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid token"})
+		if errors.Is(err, store.ErrNotFound) {
+			um.Metrics.AuthFailure("invalid_token")
+			invalidAuthentication(w, r, "token expired or invalid")
 			return
 		}
-
-		if user == nil {
-			utils.WriterJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "token expired or invalid"})
+		if err != nil {
+			um.Metrics.AuthFailure("lookup_error")
+			um.Logger.ErrorContext(r.Context(), "looking up auth token failed", "error", err)
+			utils.ServerError(w, r)
 			return
 		}
 
+		// last use is informational only, a failed write must not reject the request
+		_ = um.TokenStore.TouchToken(token)
+
 		r = SetUser(r, user)
+		r = SetToken(r, token)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -70,10 +102,29 @@ func (um *UserMiddleware) RequireUser(next http.Handler) http.Handler {
 		user := GetUser(r)
 
 		if user.IsAnonymous() {
-			utils.WriterJSON(w, http.StatusUnauthorized, utils.Envelope{"result": "unauthorized"})
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			utils.WriteError(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "you must be authenticated to access this resource")
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (um *UserMiddleware) RequireActivatedUser(next http.Handler) http.Handler {
+	return um.RequireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := GetUser(r)
+
+		if !user.Activated {
+			utils.WriteError(w, r, http.StatusForbidden, utils.CodeInactiveAccount, "your account must be activated to access this resource")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+func invalidAuthentication(w http.ResponseWriter, r *http.Request, detail string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	utils.WriteError(w, r, http.StatusUnauthorized, utils.CodeInvalidToken, detail)
+}
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS exercises (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    primary_muscle_groups TEXT[] NOT NULL DEFAULT '{}',
+    secondary_muscle_groups TEXT[] NOT NULL DEFAULT '{}',
+    equipment VARCHAR(100),
+    movement_type VARCHAR(10) NOT NULL DEFAULT 'reps',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_movement_type CHECK (movement_type IN ('reps', 'timed'))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS exercises_name_lower_idx ON exercises (lower(name));
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+ADD COLUMN exercise_id BIGINT REFERENCES exercises(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO exercises (name, movement_type)
+SELECT
+    initcap(regexp_replace(lower(trim(exercise_name)), '\s+', ' ', 'g')),
+    CASE WHEN bool_or(reps IS NOT NULL) THEN 'reps' ELSE 'timed' END
+FROM workout_entries
+WHERE trim(exercise_name) <> ''
+GROUP BY regexp_replace(lower(trim(exercise_name)), '\s+', ' ', 'g')
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE workout_entries we
+SET exercise_id = e.id
+FROM exercises e
+WHERE lower(e.name) = regexp_replace(lower(trim(we.exercise_name)), '\s+', ' ', 'g');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+DROP COLUMN exercise_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS exercises;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tokens
+ADD COLUMN family_id TEXT,
+ADD COLUMN used_at TIMESTAMP(0) WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS tokens_family_id_idx ON tokens (family_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS tokens_family_id_idx;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE tokens
+DROP COLUMN family_id,
+DROP COLUMN used_at;
+-- +goose StatementEnd
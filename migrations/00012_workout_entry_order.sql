@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+UPDATE workout_entries we
+SET order_index = ordered.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY workout_id ORDER BY order_index, id) - 1 AS position
+    FROM workout_entries
+) ordered
+WHERE we.id = ordered.id AND we.order_index <> ordered.position;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+ADD CONSTRAINT workout_entries_order_unique UNIQUE (workout_id, order_index) DEFERRABLE INITIALLY DEFERRED;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+DROP CONSTRAINT IF EXISTS workout_entries_order_unique;
+-- +goose StatementEnd
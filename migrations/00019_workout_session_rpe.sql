@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workouts
+ADD COLUMN session_rpe DECIMAL(3, 1) CONSTRAINT workouts_session_rpe_check CHECK (session_rpe BETWEEN 1 AND 10);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts
+DROP COLUMN session_rpe;
+-- +goose StatementEnd
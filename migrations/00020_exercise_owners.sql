@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- exercises without an owner belong to the shared catalog and are read-only
+ALTER TABLE exercises
+ADD COLUMN user_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE exercises
+DROP COLUMN user_id;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- most reps is tracked per weight, every other record once per exercise
+ALTER TABLE personal_records
+ADD COLUMN weight_key DECIMAL(5, 2) GENERATED ALWAYS AS (
+    CASE WHEN record_type = 'most_reps_at_weight' THEN weight ELSE 0 END
+) STORED;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DELETE FROM personal_records older
+USING personal_records newer
+WHERE older.user_id = newer.user_id
+    AND older.exercise_id = newer.exercise_id
+    AND older.record_type = newer.record_type
+    AND older.weight_key = newer.weight_key
+    AND older.id < newer.id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS personal_records_record_idx
+ON personal_records (user_id, exercise_id, record_type, weight_key);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP INDEX IF EXISTS personal_records_user_exercise_idx;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS personal_records_user_exercise_idx ON personal_records (user_id, exercise_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP INDEX IF EXISTS personal_records_record_idx;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE personal_records
+DROP COLUMN weight_key;
+-- +goose StatementEnd
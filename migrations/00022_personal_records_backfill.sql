@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Rebuilds every record from the logged entries the way
+-- ComputePersonalRecords does: per user, exercise and record type (and weight
+-- for most reps) the first entry that reached the best rounded value wins.
+DELETE FROM personal_records;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+WITH candidates AS (
+    SELECT w.user_id, we.exercise_id, we.workout_id, we.id AS workout_entry_id,
+        we.reps, we.duration_seconds, we.weight, w.created_at
+    FROM workout_entries we
+    INNER JOIN workouts w ON w.id = we.workout_id
+    WHERE we.exercise_id IS NOT NULL AND w.deleted_at IS NULL
+),
+scored AS (
+    SELECT c.*, r.record_type, round(r.value, 2) AS value,
+        CASE WHEN r.record_type = 'most_reps_at_weight' THEN c.weight ELSE 0 END AS weight_key
+    FROM candidates c
+    CROSS JOIN LATERAL (VALUES
+        ('longest_duration', c.duration_seconds::numeric),
+        ('heaviest_weight', c.weight),
+        ('most_reps_at_weight', CASE WHEN c.weight IS NOT NULL THEN c.reps::numeric END),
+        ('estimated_1rm_epley', CASE
+            WHEN c.reps = 1 THEN c.weight
+            WHEN c.reps > 1 THEN c.weight * (1 + c.reps / 30.0)
+        END),
+        ('estimated_1rm_brzycki', CASE
+            WHEN c.reps BETWEEN 1 AND 36 THEN c.weight * 36 / (37 - c.reps)
+        END)
+    ) AS r(record_type, value)
+    WHERE r.value > 0
+)
+INSERT INTO personal_records (user_id, exercise_id, record_type, value, weight, reps, duration_seconds, workout_id, workout_entry_id, achieved_at)
+SELECT DISTINCT ON (user_id, exercise_id, record_type, weight_key)
+    user_id, exercise_id, record_type, value, weight, reps, duration_seconds, workout_id, workout_entry_id, created_at
+FROM scored
+ORDER BY user_id, exercise_id, record_type, weight_key, value DESC, created_at, workout_entry_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- the rebuilt records are kept, they are what the application computes too
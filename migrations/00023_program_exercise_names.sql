@@ -0,0 +1,93 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Like template entries, prescriptions and training maxes keep the exercise
+-- name and lose only the link when the exercise is deleted.
+ALTER TABLE program_prescriptions
+ADD COLUMN exercise_name VARCHAR(255) NOT NULL DEFAULT '',
+DROP CONSTRAINT program_prescriptions_pkey;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE program_prescriptions
+ALTER COLUMN exercise_id DROP NOT NULL,
+DROP CONSTRAINT program_prescriptions_exercise_id_fkey,
+ADD CONSTRAINT program_prescriptions_exercise_id_fkey FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE program_prescriptions
+ADD COLUMN id BIGSERIAL PRIMARY KEY,
+ADD CONSTRAINT program_prescriptions_exercise_unique UNIQUE (session_id, exercise_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE program_prescriptions p SET exercise_name = e.name
+FROM exercises e
+WHERE e.id = p.exercise_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE program_prescriptions
+ALTER COLUMN exercise_name DROP DEFAULT;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE training_maxes
+ADD COLUMN exercise_name VARCHAR(255) NOT NULL DEFAULT '',
+DROP CONSTRAINT training_maxes_pkey;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE training_maxes
+ALTER COLUMN exercise_id DROP NOT NULL,
+DROP CONSTRAINT training_maxes_exercise_id_fkey,
+ADD CONSTRAINT training_maxes_exercise_id_fkey FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE training_maxes
+ADD COLUMN id BIGSERIAL PRIMARY KEY,
+ADD CONSTRAINT training_maxes_exercise_unique UNIQUE (enrollment_id, exercise_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE training_maxes t SET exercise_name = e.name
+FROM exercises e
+WHERE e.id = t.exercise_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE training_maxes
+ALTER COLUMN exercise_name DROP DEFAULT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM training_maxes WHERE exercise_id IS NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE training_maxes
+DROP CONSTRAINT training_maxes_exercise_unique,
+DROP COLUMN id,
+DROP COLUMN exercise_name,
+ALTER COLUMN exercise_id SET NOT NULL,
+ADD PRIMARY KEY (enrollment_id, exercise_id),
+DROP CONSTRAINT training_maxes_exercise_id_fkey,
+ADD CONSTRAINT training_maxes_exercise_id_fkey FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DELETE FROM program_prescriptions WHERE exercise_id IS NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE program_prescriptions
+DROP CONSTRAINT program_prescriptions_exercise_unique,
+DROP COLUMN id,
+DROP COLUMN exercise_name,
+ALTER COLUMN exercise_id SET NOT NULL,
+ADD PRIMARY KEY (session_id, exercise_id),
+DROP CONSTRAINT program_prescriptions_exercise_id_fkey,
+ADD CONSTRAINT program_prescriptions_exercise_id_fkey FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE;
+-- +goose StatementEnd